@@ -0,0 +1,93 @@
+package alerts
+
+import "time"
+
+// Policy controls when a firing watcher sends notifications. The zero
+// policy notifies once when a watcher starts firing.
+type Policy struct {
+	// MinFirings is the number of consecutive firings before notifying
+	MinFirings int
+	// Cooldown is the minimum time between two notifications
+	Cooldown time.Duration
+	// RepeatCycles repeats the notification every n cycles while firing
+	RepeatCycles int
+	// RepeatEvery repeats the notification after a duration while firing
+	RepeatEvery time.Duration
+	// Resolved sends a notification when the watcher stops firing
+	Resolved bool
+}
+
+// Action is the result of updating the state of a watcher
+type Action int
+
+// Actions after a watcher ran
+const (
+	None Action = iota
+	Notify
+	Resolve
+)
+
+// State is the firing state of a watcher
+type State struct {
+	// Count is the number of consecutive firings
+	Count uint64 `json:"count"`
+	// FirstFired is the time of the first of the consecutive firings
+	FirstFired time.Time `json:"first_fired"`
+	// LastNotified is the time of the last notification
+	LastNotified time.Time `json:"last_notified"`
+	// NotifiedCount is the firing count at the last notification
+	NotifiedCount uint64 `json:"notified_count"`
+	// Active is set while a sent alert has not been resolved
+	Active bool `json:"active"`
+}
+
+// Update records the result of a watcher run and returns the action to take
+func (s *State) Update(fired bool, now time.Time, p Policy) Action {
+	if !fired {
+		action := None
+		if s.Active && p.Resolved {
+			action = Resolve
+		}
+
+		// the last notification is kept for the cooldown
+		*s = State{LastNotified: s.LastNotified}
+
+		return action
+	}
+
+	s.Count++
+	if s.Count == 1 {
+		s.FirstFired = now
+	}
+
+	if s.Count < uint64(p.MinFirings) {
+		return None
+	}
+
+	if p.Cooldown > 0 && now.Sub(s.LastNotified) < p.Cooldown {
+		return None
+	}
+
+	if !s.Active {
+		return s.notify(now)
+	}
+
+	// repeat while still firing
+	if p.RepeatCycles > 0 && s.Count-s.NotifiedCount >= uint64(p.RepeatCycles) {
+		return s.notify(now)
+	}
+
+	if p.RepeatEvery > 0 && now.Sub(s.LastNotified) >= p.RepeatEvery {
+		return s.notify(now)
+	}
+
+	return None
+}
+
+func (s *State) notify(now time.Time) Action {
+	s.Active = true
+	s.LastNotified = now
+	s.NotifiedCount = s.Count
+
+	return Notify
+}
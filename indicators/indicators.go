@@ -0,0 +1,360 @@
+package indicators
+
+import (
+	"math"
+	"sort"
+)
+
+// Candle holds the prices of a single tick
+type Candle struct {
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+}
+
+// Indicator is an indicator that is updated one candle at a time
+type Indicator interface {
+	Update(c Candle) []float64
+	Clone() Indicator
+}
+
+// New returns the incremental implementation of an indicator type and the
+// labels of its outputs, or nil if the type has none
+func New(typ string, params []int) (Indicator, []string) {
+	switch typ {
+	case "sma":
+		return NewSMA(params[0]), []string{""}
+	case "ema":
+		return NewEMA(params[0]), []string{""}
+	case "rsi":
+		return NewRSI(params[0]), []string{""}
+	case "macd":
+		return NewMACD(params[0], params[1], params[2]), []string{"", "_Sig", "_Hist"}
+	case "atr":
+		return NewATR(params[0]), []string{""}
+	}
+
+	return nil, nil
+}
+
+// SMA holds the state of a simple moving average
+type SMA struct {
+	Period int
+	Values []float64
+	Pos    int
+	Count  int
+	Sum    float64
+}
+
+// NewSMA creates a simple moving average
+func NewSMA(period int) *SMA {
+	return &SMA{Period: period, Values: make([]float64, period)}
+}
+
+// Add adds a value and returns the average, or 0 until Period values were added
+func (s *SMA) Add(v float64) float64 {
+	result := 0.0
+
+	s.Values[s.Pos] = v
+	s.Sum += v
+	s.Count++
+
+	if s.Count >= s.Period {
+		result = s.Sum / float64(s.Period)
+
+		// remove the oldest value from the window
+		s.Sum -= s.Values[(s.Pos+1)%s.Period]
+	}
+
+	s.Pos = (s.Pos + 1) % s.Period
+
+	return result
+}
+
+// Update adds a candle using its close price
+func (s *SMA) Update(c Candle) []float64 {
+	return []float64{s.Add(c.Close)}
+}
+
+// Clone returns a copy of the state
+func (s *SMA) Clone() Indicator {
+	clone := *s
+	clone.Values = append([]float64(nil), s.Values...)
+	return &clone
+}
+
+// EMA holds the state of an exponential moving average
+type EMA struct {
+	Period int
+	K      float64
+	Count  int
+	Sum    float64
+	Value  float64
+}
+
+// NewEMA creates an exponential moving average
+func NewEMA(period int) *EMA {
+	return &EMA{Period: period, K: 2.0 / float64(period+1)}
+}
+
+// Add adds a value and returns the average, or 0 until Period values were added
+func (e *EMA) Add(v float64) float64 {
+	e.Count++
+
+	switch {
+	case e.Count < e.Period:
+		e.Sum += v
+		return 0
+	case e.Count == e.Period:
+		// seed with the simple average
+		e.Sum += v
+		e.Value = e.Sum / float64(e.Period)
+	default:
+		e.Value = ((v - e.Value) * e.K) + e.Value
+	}
+
+	return e.Value
+}
+
+// Update adds a candle using its close price
+func (e *EMA) Update(c Candle) []float64 {
+	return []float64{e.Add(c.Close)}
+}
+
+// Clone returns a copy of the state
+func (e *EMA) Clone() Indicator {
+	clone := *e
+	return &clone
+}
+
+// RSI holds the state of a relative strength index
+type RSI struct {
+	Period int
+	Count  int
+	Prev   float64
+	Gain   float64
+	Loss   float64
+}
+
+// NewRSI creates a relative strength index
+func NewRSI(period int) *RSI {
+	return &RSI{Period: period}
+}
+
+// Add adds a value and returns the index, or 0 until Period changes were added
+func (r *RSI) Add(v float64) float64 {
+	r.Count++
+
+	if r.Period < 2 {
+		return 0
+	}
+
+	if r.Count == 1 {
+		r.Prev = v
+		return 0
+	}
+
+	diff := v - r.Prev
+	r.Prev = v
+
+	// number of changes seen so far
+	n := r.Count - 1
+	period := float64(r.Period)
+
+	if n > r.Period {
+		r.Loss *= period - 1
+		r.Gain *= period - 1
+	}
+
+	if diff < 0 {
+		r.Loss -= diff
+	} else {
+		r.Gain += diff
+	}
+
+	if n < r.Period {
+		return 0
+	}
+
+	r.Loss /= period
+	r.Gain /= period
+
+	total := r.Gain + r.Loss
+	if -0.00000000000001 < total && total < 0.00000000000001 {
+		return 0
+	}
+
+	return 100.0 * (r.Gain / total)
+}
+
+// Update adds a candle using its close price
+func (r *RSI) Update(c Candle) []float64 {
+	return []float64{r.Add(c.Close)}
+}
+
+// Clone returns a copy of the state
+func (r *RSI) Clone() Indicator {
+	clone := *r
+	return &clone
+}
+
+// MACD holds the state of a moving average convergence/divergence
+type MACD struct {
+	Fast     EMA
+	Slow     EMA
+	Signal   EMA
+	Lookback int
+	Count    int
+}
+
+// NewMACD creates a moving average convergence/divergence
+func NewMACD(fast int, slow int, signal int) *MACD {
+	if slow < fast {
+		slow, fast = fast, slow
+	}
+
+	return &MACD{
+		Fast:     *NewEMA(fast),
+		Slow:     *NewEMA(slow),
+		Signal:   *NewEMA(signal),
+		Lookback: (signal - 1) + (slow - 1),
+	}
+}
+
+// Add adds a value and returns the MACD, signal and histogram values
+func (m *MACD) Add(v float64) (float64, float64, float64) {
+	i := m.Count
+	m.Count++
+
+	fast := m.Fast.Add(v)
+	slow := m.Slow.Add(v)
+
+	macd, hist := 0.0, 0.0
+	if i >= m.Lookback-1 {
+		macd = fast - slow
+	}
+
+	// the signal line also averages the leading zeros, as talib does
+	signal := m.Signal.Add(macd)
+
+	if i >= m.Lookback {
+		hist = macd - signal
+	}
+
+	return macd, signal, hist
+}
+
+// Update adds a candle using its close price
+func (m *MACD) Update(c Candle) []float64 {
+	macd, signal, hist := m.Add(c.Close)
+	return []float64{macd, signal, hist}
+}
+
+// Clone returns a copy of the state
+func (m *MACD) Clone() Indicator {
+	clone := *m
+	return &clone
+}
+
+// ATR holds the state of an average true range
+type ATR struct {
+	Period int
+	Count  int
+	Prev   float64
+	Sum    float64
+	Value  float64
+}
+
+// NewATR creates an average true range
+func NewATR(period int) *ATR {
+	return &ATR{Period: period}
+}
+
+// Update adds a candle and returns the average true range
+func (a *ATR) Update(c Candle) []float64 {
+	a.Count++
+
+	if a.Count == 1 || a.Period < 1 {
+		a.Prev = c.Close
+		return []float64{0}
+	}
+
+	// true range
+	tr := c.High - c.Low
+	if v := math.Abs(a.Prev - c.High); v > tr {
+		tr = v
+	}
+	if v := math.Abs(a.Prev - c.Low); v > tr {
+		tr = v
+	}
+	a.Prev = c.Close
+
+	// number of true ranges seen so far
+	n := a.Count - 1
+	period := float64(a.Period)
+
+	switch {
+	case a.Period == 1:
+		a.Value = tr
+	case n < a.Period:
+		a.Sum += tr
+		return []float64{0}
+	case n == a.Period:
+		a.Sum += tr
+		a.Value = a.Sum / period
+	default:
+		a.Value *= period - 1.0
+		a.Value += tr
+		a.Value /= period
+	}
+
+	return []float64{a.Value}
+}
+
+// Clone returns a copy of the state
+func (a *ATR) Clone() Indicator {
+	clone := *a
+	return &clone
+}
+
+// Align returns the times present in all series, in ascending order, and
+// the values of each series at those times
+func Align(times [][]int, series [][]float64) ([]int, [][]float64) {
+	counts := make(map[int]int)
+	for _, t := range times {
+		seen := make(map[int]bool, len(t))
+		for _, time := range t {
+			if !seen[time] {
+				seen[time] = true
+				counts[time]++
+			}
+		}
+	}
+
+	var common []int
+	for time, n := range counts {
+		if n == len(times) {
+			common = append(common, time)
+		}
+	}
+	sort.Ints(common)
+
+	result := make([][]float64, len(series))
+	for i := range series {
+		index := make(map[int]float64, len(times[i]))
+		for j, time := range times[i] {
+			if j < len(series[i]) {
+				index[time] = series[i][j]
+			}
+		}
+
+		result[i] = make([]float64, len(common))
+		for j, time := range common {
+			result[i][j] = index[time]
+		}
+	}
+
+	return common, result
+}
@@ -0,0 +1,62 @@
+package indicators
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	talib "github.com/markcheno/go-talib"
+)
+
+// prices returns a random walk of n candles
+func prices(n int) (high, low, close []float64) {
+	r := rand.New(rand.NewSource(1))
+
+	p := 100.0
+	for i := 0; i < n; i++ {
+		p += r.NormFloat64()
+		close = append(close, p)
+		high = append(high, p+r.Float64())
+		low = append(low, p-r.Float64())
+	}
+
+	return high, low, close
+}
+
+// compare updates the indicator with all candles and compares each output
+// with the talib batch results, the state is cloned halfway through
+func compare(t *testing.T, name string, indicator Indicator, high, low, close []float64, want ...[]float64) {
+	t.Helper()
+
+	for i := range close {
+		if i == len(close)/2 {
+			indicator = indicator.Clone()
+		}
+
+		output := indicator.Update(Candle{Open: close[i], High: high[i], Low: low[i], Close: close[i]})
+
+		for k := range want {
+			if math.Abs(output[k]-want[k][i]) > 1e-9 {
+				t.Fatalf("%s: output %d at %d is %v, want %v", name, k, i, output[k], want[k][i])
+			}
+		}
+	}
+}
+
+func TestTalibEquality(t *testing.T) {
+	high, low, close := prices(300)
+
+	for _, period := range []int{1, 2, 14} {
+		compare(t, "sma", NewSMA(period), high, low, close, talib.Sma(close, period))
+		compare(t, "ema", NewEMA(period), high, low, close, talib.Ema(close, period))
+		compare(t, "rsi", NewRSI(period), high, low, close, talib.Rsi(close, period))
+		compare(t, "atr", NewATR(period), high, low, close, talib.Atr(high, low, close, period))
+	}
+
+	macd, signal, hist := talib.Macd(close, 12, 26, 9)
+	compare(t, "macd", NewMACD(12, 26, 9), high, low, close, macd, signal, hist)
+
+	// talib swaps fast and slow periods
+	macd, signal, hist = talib.Macd(close, 26, 12, 9)
+	compare(t, "macd swapped", NewMACD(26, 12, 9), high, low, close, macd, signal, hist)
+}
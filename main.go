@@ -3,16 +3,21 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"./alerts"
 	cc "./cryptocompare"
+	ind "./indicators"
+	"./notifiers"
 	ss "./scriptstate"
+	st "./storage"
 	ti "./tradeinterval"
 	yaml "gopkg.in/yaml.v2"
 
@@ -20,16 +25,37 @@ import (
 )
 
 type indicator struct {
-	Name   string `json:"name" yaml:"name"`
-	Type   string `json:"type" yaml:"type"`
-	Params []int  `json:"params" yaml:"params"`
+	Name    string   `json:"name" yaml:"name"`
+	Type    string   `json:"type" yaml:"type"`
+	Params  []int    `json:"params" yaml:"params"`
+	Lua     string   `json:"lua" yaml:"lua"`
+	LuaFile string   `json:"lua_file" yaml:"lua_file"`
+	Inputs  []string `json:"inputs" yaml:"inputs"`
+
+	luaChunk *ss.Chunk
 }
 
 type watcher struct {
-	Name   string   `json:"name" yaml:"name"`
-	Lua    string   `json:"lua" yaml:"lua"`
-	Expr   string   `json:"expr" yaml:"expr"`
-	Values []string `json:"values" yaml:"values"`
+	Name     string   `json:"name" yaml:"name"`
+	Lua      string   `json:"lua" yaml:"lua"`
+	LuaFile  string   `json:"lua_file" yaml:"lua_file"`
+	Starlark string   `json:"starlark" yaml:"starlark"`
+	Expr     string   `json:"expr" yaml:"expr"`
+	Values   []string `json:"values" yaml:"values"`
+	Severity string   `json:"severity" yaml:"severity"`
+	Tags     []string `json:"tags" yaml:"tags"`
+
+	// re-notification policy
+	MinFirings  int    `json:"min_firings" yaml:"min_firings"`
+	Cooldown    string `json:"cooldown" yaml:"cooldown"`
+	Repeat      int    `json:"repeat" yaml:"repeat"`
+	RepeatEvery string `json:"repeat_every" yaml:"repeat_every"`
+	Resolved    bool   `json:"resolved" yaml:"resolved"`
+
+	policy          alerts.Policy
+	luaChunk        *ss.Chunk
+	starlarkProgram *ss.StarlarkProgram
+	exprProgram     *ss.Expr
 }
 
 type tradingpair struct {
@@ -40,26 +66,20 @@ type tradingpair struct {
 	Exchange   string      `json:"exchange" yaml:"exchange"`
 	Interval   string      `json:"interval" yaml:"interval"`
 	Length     int         `json:"length" yaml:"length"`
+	Streaming  bool        `json:"streaming" yaml:"streaming"`
 	Update     []string    `json:"update" yaml:"update"`
 	Indicators []indicator `json:"indicators" yaml:"indicators"`
 	Watchers   []watcher   `json:"watchers" yaml:"watchers"`
+	Tags       []string    `json:"tags" yaml:"tags"`
+	Templates  []string    `json:"templates" yaml:"templates"`
+	// Params overrides template params by template name, e.g.
+	// {rsi_low: {threshold: 25}}
+	Params map[string]map[string]interface{} `json:"params" yaml:"params"`
 }
 
-type notifier struct {
-	Type      string `json:"type" yaml:"type"`
-	Recipient string `json:"recipient" yaml:"recipient"`
-	Sender    string `json:"sender" yaml:"sender"`
-	Auth      string `json:"auth" yaml:"auth"`
-	Format    string `json:"format" yaml:"format"`
-}
+type notifier = notifiers.Config
 
-type notification struct {
-	Timestamp string             `json:"timestamp" yaml:"timestamp"`
-	Message   string             `json:"message" yaml:"message"`
-	Source    string             `json:"source" yaml:"source"`
-	Code      string             `json:"code" yaml:"code"`
-	Values    map[string]float64 `json:"values" yaml:"values"`
-}
+type notification = notifiers.Notification
 
 type timeseries = []float64
 type dataset map[string]timeseries
@@ -70,113 +90,185 @@ type key struct {
 	Tradingpair, Watcher string
 }
 
-var cache map[key]uint64
+func (k key) String() string {
+	return k.Tradingpair + "/" + k.Watcher
+}
+
+var cache map[key]*alerts.State
+
+type watcherError struct {
+	count    uint64
+	notified time.Time
+}
+
+var watcherErrors map[key]*watcherError
+
+// script memory per tradingpair and per watcher
+var memory struct {
+	Tradingpairs map[string]ss.Memory `json:"tradingpairs"`
+	Watchers     map[string]ss.Memory `json:"watchers"`
+}
+
+type streamKey struct {
+	Tradingpair, Indicator string
+}
+
+type stream struct {
+	indicator ind.Indicator
+	labels    []string
+	time      int
+	outputs   map[int][]float64
+}
+
+var streams map[streamKey]*stream
 
 var config struct {
-	Tradingpairs []tradingpair `json:"tradingpairs" yaml:"tradingpairs"`
-	Watchers     []watcher     `json:"watchers" yaml:"watchers"`
-	Notifiers    []notifier    `json:"notifiers" yaml:"notifiers"`
-	Update       string        `json:"update" yaml:"update"`
-	Verbose      bool          `json:"verbose" yaml:"verbose"`
+	Tradingpairs       []tradingpair       `json:"tradingpairs" yaml:"tradingpairs"`
+	Indicators         []indicator         `json:"indicators" yaml:"indicators"`
+	Watchers           []watcher           `json:"watchers" yaml:"watchers"`
+	IndicatorTemplates []indicatorTemplate `json:"indicator_templates" yaml:"indicator_templates"`
+	WatcherTemplates   []watcherTemplate   `json:"watcher_templates" yaml:"watcher_templates"`
+	Notifiers          []notifier          `json:"notifiers" yaml:"notifiers"`
+	Update             string              `json:"update" yaml:"update"`
+	LuaTimeout         string              `json:"lua_timeout" yaml:"lua_timeout"`
+	LuaPath            string              `json:"lua_path" yaml:"lua_path"`
+	DataDir            string              `json:"datadir" yaml:"datadir"`
+	Verbose            bool                `json:"verbose" yaml:"verbose"`
+	Errors             struct {
+		Notify   bool   `json:"notify" yaml:"notify"`
+		Interval string `json:"interval" yaml:"interval"`
+	} `json:"errors" yaml:"errors"`
 }
 
 func reverse(numbers timeseries) timeseries {
 	newNumbers := make(timeseries, len(numbers))
-	for i, j := 0, len(numbers)-1; i < j; i, j = i+1, j-1 {
+	for i, j := 0, len(numbers)-1; i <= j; i, j = i+1, j-1 {
 		newNumbers[i], newNumbers[j] = numbers[j], numbers[i]
 	}
 	return newNumbers
 }
 
-func (n notification) format(template string) string {
-	message, values := "", ""
-
-	for k, v := range n.Values {
-		if v < 1 {
-			// 0 digits
-			values += fmt.Sprintf("%-12s: %-8.6f\n", strings.Title(k), v)
-		} else if v < 1000 {
-			// three digits
-			values += fmt.Sprintf("%-12s: %-8.2f\n", strings.Title(k), v)
-		} else {
-			// four digits
-			values += fmt.Sprintf("%-12s: %-8.0f\n", strings.Title(k), v)
-		}
-	}
+// delivery queues of the notifiers, created from the config at startup
+var queues []*notifiers.Queue
 
-	if n.Source != "" {
-		message += n.Source + "\n"
-	}
+// deadLetters guards the dead letter file
+var deadLetters sync.Mutex
 
-	message += n.Message + "\n"
+type deadLetter struct {
+	Notifier     string       `json:"notifier"`
+	Error        string       `json:"error"`
+	Attempts     int          `json:"attempts"`
+	Notification notification `json:"notification"`
+}
 
-	switch template {
-	case "normal":
-		if values != "" {
-			message += "\n" + values
+func setupNotifiers() {
+	for i, cfg := range config.Notifiers {
+		n, err := notifiers.New(cfg)
+		if err != nil {
+			log.Fatal(err)
 		}
-	case "long":
-		if values != "" {
-			message += "\n" + values
+
+		name := fmt.Sprintf("%s#%d", cfg.Type, i+1)
+
+		q, err := notifiers.NewQueue(name, n, cfg, delivered)
+		if err != nil {
+			log.Fatalf("%s notifier: %v", cfg.Type, err)
 		}
 
-		message += "\n" + n.Code
-	default:
+		queues = append(queues, q)
 	}
-
-	return message
 }
 
-func sendNotification(n notification) {
-	for _, notif := range config.Notifiers {
-		switch notif.Type {
-		case "telegram":
-			notifyTelegram(n, notif)
-		default:
-			fmt.Printf("%v---\n", n.format("normal"))
+// delivered logs the outcome of a delivery, notifications that could not
+// be delivered are written to the dead letter file
+func delivered(r notifiers.Result) {
+	if r.Err == nil {
+		if config.Verbose || r.Attempts > 1 {
+			log.Printf("%s: delivered after %d attempts", r.Notifier, r.Attempts)
 		}
+		return
 	}
-}
 
-func notifyTelegram(n notification, notif notifier) {
-	message := url.QueryEscape(n.format(notif.Format))
+	log.Printf("%s: delivery failed after %d attempts: %v", r.Notifier, r.Attempts, r.Err)
 
-	link := "https://api.telegram.org/bot{botId}:{apiKey}/sendMessage?chat_id={chatId}&text={text}"
+	deadLetters.Lock()
+	defer deadLetters.Unlock()
+
+	entry := deadLetter{r.Notifier, r.Err.Error(), r.Attempts, *r.Notification}
+	if err := st.Append(filepath.Join(config.DataDir, "deadletter.jsonl"), entry); err != nil {
+		log.Printf("saving dead letter: %v", err)
+	}
+}
 
-	link = strings.Replace(link, "{botId}", notif.Sender, -1)
-	link = strings.Replace(link, "{apiKey}", notif.Auth, -1)
-	link = strings.Replace(link, "{chatId}", notif.Recipient, -1)
-	link = strings.Replace(link, "{text}", message, -1)
+// flushNotifiers delivers notifications collected during a cycle
+func flushNotifiers() {
+	for _, q := range queues {
+		q.Flush()
+	}
+}
 
-	_, _ = http.Get(link)
+func sendNotification(n notification) {
+	for i, q := range queues {
+		if config.Notifiers[i].Route.Matches(n) {
+			q.Send(n)
+		}
+	}
 }
 
-func executeWatcher(state ss.State, watcher watcher) (bool, notification) {
+func executeWatcher(state ss.State, watcher watcher) (bool, notification, error) {
 	fired := false
 
 	var n notification
-	n.Timestamp = time.Now().Format(time.RFC850)
+	n.Kind = "alert"
+	n.Timestamp = time.Now().Round(0)
 	n.Message = watcher.Name
+	n.Severity = watcher.Severity
+	n.Tags = append(n.Tags, watcher.Tags...)
+
+	if n.Severity == "" {
+		n.Severity = "info"
+	}
 
-	if watcher.Lua != "" {
-		luaResult := false
-		state.SetLua("alert", func(v bool) { luaResult = v })
+	if watcher.Lua != "" || watcher.Starlark != "" {
+		var alert ss.Alert
+		var err error
 
-		err := state.EvalLua(watcher.Lua)
+		state.SetAlert(func(a ss.Alert) { alert = a })
+
+		if watcher.Lua != "" {
+			err = state.RunLua(watcher.luaChunk)
+			n.Code = watcher.Lua
+		} else {
+			err = state.RunStarlark(watcher.starlarkProgram)
+			n.Code = watcher.Starlark
+		}
 
 		if err != nil {
-			log.Fatal(err)
+			return false, n, err
 		}
 
-		if luaResult {
+		if alert.Fired {
 			fired = true
-			n.Code = watcher.Lua
+
+			// scripts can override the notification
+			if alert.Message != "" {
+				n.Message = alert.Message
+			}
+
+			if notifiers.ValidSeverity(alert.Severity) {
+				n.Severity = alert.Severity
+			} else if alert.Severity != "" {
+				log.Printf("watcher %s: unknown severity %q, using %s", watcher.Name, alert.Severity, n.Severity)
+			}
+
+			n.Tags = append(n.Tags, alert.Tags...)
+			n.Values = alert.Values
 		}
 	} else if watcher.Expr != "" {
-		res, err := state.EvalExpr(watcher.Expr)
+		res, err := state.RunExpr(watcher.exprProgram)
 
 		if err != nil {
-			log.Fatal(err)
+			return false, n, err
 		}
 
 		if res == true {
@@ -185,10 +277,161 @@ func executeWatcher(state ss.State, watcher watcher) (bool, notification) {
 		}
 	}
 
-	return fired, n
+	return fired, n, nil
+}
+
+func pairMemory(slug string) ss.Memory {
+	m := memory.Tradingpairs[slug]
+	if m == nil {
+		m = make(ss.Memory)
+		memory.Tradingpairs[slug] = m
+	}
+
+	return m
+}
+
+func watcherMemory(k key) ss.Memory {
+	m := memory.Watchers[k.String()]
+	if m == nil {
+		m = make(ss.Memory)
+		memory.Watchers[k.String()] = m
+	}
+
+	return m
+}
+
+func loadMemory() {
+	memory.Tradingpairs = make(map[string]ss.Memory)
+	memory.Watchers = make(map[string]ss.Memory)
+
+	if err := st.Load(filepath.Join(config.DataDir, "memory.json"), &memory); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func saveMemory() {
+	for _, m := range memory.Tradingpairs {
+		m.Normalize()
+	}
+
+	for _, m := range memory.Watchers {
+		m.Normalize()
+	}
+
+	if err := st.Save(filepath.Join(config.DataDir, "memory.json"), &memory); err != nil {
+		log.Printf("saving script memory: %v", err)
+	}
+}
+
+func loadAlerts() {
+	states := make(map[string]*alerts.State)
+
+	if err := st.Load(filepath.Join(config.DataDir, "alerts.json"), &states); err != nil {
+		log.Fatal(err)
+	}
+
+	for name, state := range states {
+		if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+			cache[key{parts[0], parts[1]}] = state
+		}
+	}
+}
+
+func saveAlerts() {
+	states := make(map[string]*alerts.State, len(cache))
+
+	for k, state := range cache {
+		states[k.String()] = state
+	}
+
+	if err := st.Save(filepath.Join(config.DataDir, "alerts.json"), states); err != nil {
+		log.Printf("saving alert state: %v", err)
+	}
+}
+
+func processAlert(notifications chan<- notification, k key, w watcher, fired bool, n notification, results dataset) {
+	state := cache[k]
+	if state == nil {
+		state = &alerts.State{}
+		cache[k] = state
+	}
+
+	switch state.Update(fired, time.Now(), w.policy) {
+	case alerts.Notify:
+	case alerts.Resolve:
+		n.Kind = "resolved"
+		n.Message = "Resolved: " + w.Name
+	default:
+		return
+	}
+
+	n.Tradingpair = k.Tradingpair
+	n.Watcher = k.Watcher
+
+	// set return values
+	if n.Values == nil {
+		n.Values = make(map[string]float64)
+	}
+
+	for _, key := range w.Values {
+		if series := results[key]; len(series) > 0 {
+			n.Values[key] = series[len(series)-1]
+		}
+	}
+
+	// send notification
+	notifications <- n
 }
 
-func processIndicators(src ohlcv5, idc indicator) ([]timeseries, []string) {
+func watcherFailed(notifications chan<- notification, k key, source string, watcher watcher, err error) {
+	e := watcherErrors[k]
+	if e == nil {
+		e = &watcherError{}
+		watcherErrors[k] = e
+	}
+
+	e.count++
+
+	log.Printf("watcher %s/%s failed (%d errors): %v", k.Tradingpair, k.Watcher, e.count, err)
+
+	if !config.Errors.Notify {
+		return
+	}
+
+	// rate limit error notifications per watcher
+	interval, perr := time.ParseDuration(config.Errors.Interval)
+	if perr != nil {
+		interval = time.Hour
+	}
+
+	if time.Since(e.notified) < interval {
+		return
+	}
+
+	e.notified = time.Now()
+
+	var n notification
+	n.Kind = "error"
+	n.Timestamp = time.Now().Round(0)
+	n.Message = fmt.Sprintf("Watcher error: %s: %v", watcher.Name, err)
+	n.Source = source
+	n.Tradingpair = k.Tradingpair
+	n.Watcher = k.Watcher
+	n.Code = watcher.Lua + watcher.Starlark + watcher.Expr
+	n.Values = map[string]float64{"errors": float64(e.count)}
+
+	notifications <- n
+}
+
+// indicatorParams is the number of params of each processIndicators type
+var indicatorParams = map[string]int{
+	"sma": 1, "ema": 1, "dema": 1, "tema": 1, "wma": 1, "rsi": 1,
+	"stochrsi": 3, "stoch": 3, "macd": 3,
+	"mom": 1, "mfi": 1, "adx": 1, "roc": 1, "obv": 0, "atr": 1, "natr": 1,
+	"linearreg": 1, "max": 1, "min": 1, "lua": 0,
+}
+
+func processIndicators(state ss.State, src ohlcv5, idc indicator) ([]timeseries, []string) {
 	var result []timeseries
 	var labels []string
 
@@ -259,14 +502,194 @@ func processIndicators(src ohlcv5, idc indicator) ([]timeseries, []string) {
 	case "min":
 		r1 := talib.Min(src[3], idc.Params[0])
 		result = append(result, r1)
+	case "lua":
+		series, err := state.RunLuaSeries(idc.luaChunk)
+
+		if err != nil {
+			log.Printf("indicator %s failed: %v", idc.Name, err)
+			break
+		}
+
+		var names []string
+		for name := range series {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			// lua series are newest first, pad to the input length
+			r1 := make(timeseries, len(src[3]))
+			for i, v := range series[name] {
+				if i < len(r1) {
+					r1[len(r1)-1-i] = v
+				}
+			}
+
+			result = append(result, r1)
+
+			if name == "" {
+				labels = append(labels, "")
+			} else {
+				labels = append(labels, "_"+name)
+			}
+		}
+	}
+
+	// single outputs are not labeled
+	for len(labels) < len(result) {
+		labels = append(labels, "")
 	}
 
 	return result, labels
 }
 
+func processGlobalIndicator(state ss.State, results dataset, times map[string][]int, idc indicator) ([]int, []timeseries, []string) {
+	var inputTimes [][]int
+	var inputs []timeseries
+
+	for _, name := range idc.Inputs {
+		// inputs are missing when their indicator failed
+		if _, ok := results[name]; !ok {
+			log.Printf("indicator %s: unknown input %s, skipped", idc.Name, name)
+			return nil, nil, nil
+		}
+
+		inputTimes = append(inputTimes, times[name])
+		inputs = append(inputs, results[name])
+	}
+
+	// align inputs by tick time
+	time, src := ind.Align(inputTimes, inputs)
+
+	if len(time) == 0 {
+		return nil, nil, nil
+	}
+
+	// talib needs at least one period of aligned data
+	if len(idc.Params) > 0 && len(time) < idc.Params[0] {
+		return nil, nil, nil
+	}
+
+	var result []timeseries
+	var labels []string
+
+	switch idc.Type {
+	case "ratio":
+		r1 := make(timeseries, len(time))
+		for i := range r1 {
+			if src[1][i] != 0 {
+				r1[i] = src[0][i] / src[1][i]
+			}
+		}
+		result = append(result, r1)
+	case "spread":
+		r1 := make(timeseries, len(time))
+		for i := range r1 {
+			r1[i] = src[0][i] - src[1][i]
+		}
+		result = append(result, r1)
+	case "correl":
+		r1 := talib.Correl(src[0], src[1], idc.Params[0])
+		result = append(result, r1)
+	case "zscore":
+		// z-score of a single input or of the spread of two inputs
+		x := src[0]
+		if len(src) > 1 {
+			x = make(timeseries, len(time))
+			for i := range x {
+				x[i] = src[0][i] - src[1][i]
+			}
+		}
+
+		mean := talib.Sma(x, idc.Params[0])
+		dev := talib.StdDev(x, idc.Params[0], 1)
+
+		r1 := make(timeseries, len(time))
+		for i := range r1 {
+			if dev[i] != 0 {
+				r1[i] = (x[i] - mean[i]) / dev[i]
+			}
+		}
+		result = append(result, r1)
+	default:
+		// apply a tradingpair indicator to the first input
+		x := src[0]
+		result, labels = processIndicators(state, ohlcv5{x, x, x, x, make(timeseries, len(x))}, idc)
+	}
+
+	for len(labels) < len(result) {
+		labels = append(labels, "")
+	}
+
+	return time, result, labels
+}
+
+func streamIndicators(k streamKey, data []cc.Tick, idc indicator) ([]timeseries, []string) {
+	// no data, e.g. after a failed request
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	s := streams[k]
+
+	if s == nil {
+		indicator, labels := ind.New(idc.Type, idc.Params)
+
+		if indicator == nil {
+			return nil, nil
+		}
+
+		s = &stream{indicator: indicator, labels: labels, outputs: make(map[int][]float64)}
+		streams[k] = s
+	}
+
+	candle := func(tick cc.Tick) ind.Candle {
+		return ind.Candle{Open: tick.Open, High: tick.High, Low: tick.Low, Close: tick.Close, Volume: tick.VolumeFrom}
+	}
+
+	last := len(data) - 1
+
+	// add closed candles not seen before
+	for _, tick := range data[:last] {
+		if tick.Time > s.time {
+			s.outputs[tick.Time] = s.indicator.Update(candle(tick))
+			s.time = tick.Time
+		}
+	}
+
+	// the last candle is still open, update a copy
+	current := s.indicator.Clone().Update(candle(data[last]))
+
+	result := make([]timeseries, len(s.labels))
+	for i := range result {
+		result[i] = make(timeseries, len(data))
+	}
+
+	for j, tick := range data {
+		output := s.outputs[tick.Time]
+		if j == last {
+			output = current
+		}
+
+		for i, v := range output {
+			result[i][j] = v
+		}
+	}
+
+	// forget outputs that left the window
+	for time := range s.outputs {
+		if time < data[0].Time {
+			delete(s.outputs, time)
+		}
+	}
+
+	return result, s.labels
+}
+
 func mainLoop(notifications chan<- notification, results chan<- dataset) {
 	// global results
 	globalResults := make(dataset)
+	globalTimes := make(map[string][]int)
 
 	// global script state
 	var globalState ss.State
@@ -319,6 +742,12 @@ func mainLoop(notifications chan<- notification, results chan<- dataset) {
 		globalResults[t.Slug+"_close"] = close
 		globalResults[t.Slug+"_vol"] = vol
 
+		// set global result times
+		times := cc.Time(data)
+		for _, name := range []string{"open", "high", "low", "close", "vol"} {
+			globalTimes[t.Slug+"_"+name] = times
+		}
+
 		// set local result data
 		localResults["open"] = open
 		localResults["high"] = high
@@ -332,11 +761,11 @@ func mainLoop(notifications chan<- notification, results chan<- dataset) {
 		globalState.SetAll(t.Slug+"_interval", t.Interval)
 		globalState.SetAll(t.Slug+"_length", t.Length)
 
-		globalState.SetBoth(t.Slug+"_open", rOpen[0], rOpen)
-		globalState.SetBoth(t.Slug+"_high", rHigh[0], rHigh)
-		globalState.SetBoth(t.Slug+"_low", rLow[0], rLow)
-		globalState.SetBoth(t.Slug+"_close", rClose[0], rClose)
-		globalState.SetBoth(t.Slug+"_vol", rVol[0], rVol)
+		globalState.SetSeries(t.Slug+"_open", rOpen)
+		globalState.SetSeries(t.Slug+"_high", rHigh)
+		globalState.SetSeries(t.Slug+"_low", rLow)
+		globalState.SetSeries(t.Slug+"_close", rClose)
+		globalState.SetSeries(t.Slug+"_vol", rVol)
 
 		// set local state
 		localState.SetAll("coin", t.Coin)
@@ -344,18 +773,27 @@ func mainLoop(notifications chan<- notification, results chan<- dataset) {
 		localState.SetAll("interval", t.Interval)
 		localState.SetAll("length", t.Length)
 
-		localState.SetBoth("open", rOpen[0], rOpen)
-		localState.SetBoth("high", rHigh[0], rHigh)
-		localState.SetBoth("low", rLow[0], rLow)
-		localState.SetBoth("close", rClose[0], rClose)
-		localState.SetBoth("vol", rVol[0], rVol)
+		localState.SetSeries("open", rOpen)
+		localState.SetSeries("high", rHigh)
+		localState.SetSeries("low", rLow)
+		localState.SetSeries("close", rClose)
+		localState.SetSeries("vol", rVol)
 
 		// process indicators
 		for _, idc := range t.Indicators {
 			// create input data
 			ohlcv := ohlcv5{open, high, low, close, vol}
 
-			outputs, labels := processIndicators(ohlcv, idc)
+			var outputs []timeseries
+			var labels []string
+
+			if t.Streaming {
+				outputs, labels = streamIndicators(streamKey{t.Slug, idc.Name}, data, idc)
+			}
+
+			if outputs == nil {
+				outputs, labels = processIndicators(localState, ohlcv, idc)
+			}
 
 			// process indicator
 			for i, output := range outputs {
@@ -363,13 +801,11 @@ func mainLoop(notifications chan<- notification, results chan<- dataset) {
 				label := labels[i]
 
 				// add indicator output to state
-				globalState.SetExpr(t.Slug+"_"+idc.Name+label, rOutput[0])
-				globalState.SetLua(t.Slug+"_"+idc.Name+label, rOutput)
-
-				localState.SetExpr(idc.Name+label, rOutput[0])
-				localState.SetLua(idc.Name+label, rOutput)
+				globalState.SetSeries(t.Slug+"_"+idc.Name+label, rOutput)
+				localState.SetSeries(idc.Name+label, rOutput)
 
 				globalResults[t.Slug+"_"+idc.Name+label] = output
+				globalTimes[t.Slug+"_"+idc.Name+label] = times
 				localResults[idc.Name+label] = output
 			}
 		}
@@ -377,77 +813,295 @@ func mainLoop(notifications chan<- notification, results chan<- dataset) {
 		// send update
 		if len(t.Update) > 0 {
 			var n notification
-			n.Timestamp = time.Now().Format(time.RFC850)
+			n.Kind = "update"
+			n.Timestamp = time.Now().Round(0)
 			n.Message = "Update"
 
 			n.Source = t.Name + " " + t.Interval
+			n.Tradingpair = t.Slug
 			n.Values = make(map[string]float64)
 
 			for _, key := range t.Update {
-				results := localResults[key]
-				n.Values[key] = results[len(results)-1]
+				if results := localResults[key]; len(results) > 0 {
+					n.Values[key] = results[len(results)-1]
+				}
 			}
 
 			notifications <- n
 		}
 
 		// execute time series watchers
+		localState.SetMemory("pair_memory", pairMemory(t.Slug))
+
 		for _, w := range t.Watchers {
 			// execute watcher
-			fired, n := executeWatcher(localState, w)
+			localState.SetMemory("memory", watcherMemory(key{t.Slug, w.Name}))
+			fired, n, err := executeWatcher(localState, w)
 
 			// process watcher result
-			if fired {
-				// check for previous notification
-				if cache[key{t.Slug, w.Name}] == 0 {
-					// set return values
-					n.Source = t.Name + " " + t.Interval
-					n.Values = make(map[string]float64)
-
-					for _, key := range w.Values {
-						results := localResults[key]
-						n.Values[key] = results[len(results)-1]
-					}
-
-					// send notification
-					notifications <- n
-				}
-
-				// increase notification counter
-				cache[key{t.Slug, w.Name}]++
+			if err != nil {
+				watcherFailed(notifications, key{t.Slug, w.Name}, t.Name+" "+t.Interval, w, err)
 			} else {
-				// reset cache
-				cache[key{t.Slug, w.Name}] = 0
+				n.Source = t.Name + " " + t.Interval
+				processAlert(notifications, key{t.Slug, w.Name}, w, fired, n, localResults)
 			}
 		}
 	}
 
+	// process global indicators
+	for _, idc := range config.Indicators {
+		times, outputs, labels := processGlobalIndicator(globalState, globalResults, globalTimes, idc)
+
+		for i, output := range outputs {
+			rOutput := reverse(output)
+			label := labels[i]
+
+			// add indicator output to state
+			globalState.SetSeries(idc.Name+label, rOutput)
+
+			globalResults[idc.Name+label] = output
+			globalTimes[idc.Name+label] = times
+		}
+	}
+
 	// execute global watchers
+	globalState.SetMemory("pair_memory", pairMemory("global"))
+
 	for _, w := range config.Watchers {
-		// execure watcher
-		fired, n := executeWatcher(globalState, w)
+		// execute watcher
+		globalState.SetMemory("memory", watcherMemory(key{"global", w.Name}))
+		fired, n, err := executeWatcher(globalState, w)
 
 		// process watcher result
-		if fired {
-			// check for previous notification
-			if cache[key{"global", w.Name}] == 0 {
-				// set return values
-				n.Values = make(map[string]float64)
-
-				for _, key := range w.Values {
-					results := globalResults[key]
-					n.Values[key] = results[len(results)-1]
-				}
+		if err != nil {
+			watcherFailed(notifications, key{"global", w.Name}, "", w, err)
+		} else {
+			processAlert(notifications, key{"global", w.Name}, w, fired, n, globalResults)
+		}
+	}
+
+	// persist script memory and alert state
+	saveMemory()
+	saveAlerts()
+
+	// end of cycle
+	results <- globalResults
+}
+
+// readScript reads a script file, relative paths start at dir
+func readScript(dir string, file string) (string, error) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	source, err := ioutil.ReadFile(file)
+
+	return string(source), err
+}
+
+// luaPath converts the configured lua_path to a Lua package.path, entries
+// without a ? are directories of modules
+func luaPath(dir string, path string) string {
+	var entries []string
+
+	for _, entry := range strings.Split(path, ";") {
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "?") {
+			entry = filepath.Join(entry, "?.lua")
+		}
+
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(dir, entry)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return strings.Join(entries, ";")
+}
+
+func (idc *indicator) compile(dir string) error {
+	var err error
+
+	if idc.LuaFile != "" {
+		if idc.Lua, err = readScript(dir, idc.LuaFile); err != nil {
+			return err
+		}
+	}
+
+	if idc.Type == "lua" {
+		idc.luaChunk, err = ss.CompileLua(idc.Name, idc.Lua)
+	}
+
+	return err
+}
 
-				// send notification
-				notifications <- n
+// checkInputs checks the inputs and parameters of a global indicator,
+// inputs must belong to a tradingpair or an earlier global indicator
+func (idc *indicator) checkInputs(prefixes []string) error {
+	inputs, params := 1, 0
+
+	switch idc.Type {
+	case "ratio", "spread":
+		inputs = 2
+	case "correl":
+		inputs, params = 2, 1
+	case "zscore":
+		params = 1
+	default:
+		// tradingpair indicators applied to the first input
+		var ok bool
+		if params, ok = indicatorParams[idc.Type]; !ok {
+			return fmt.Errorf("unknown type %s", idc.Type)
+		}
+	}
+
+	if len(idc.Inputs) < inputs {
+		return fmt.Errorf("expected %d inputs, got %d", inputs, len(idc.Inputs))
+	}
+
+	if len(idc.Params) < params {
+		return fmt.Errorf("expected %d params, got %d", params, len(idc.Params))
+	}
+
+	for _, period := range idc.Params[:params] {
+		if period < 1 {
+			return fmt.Errorf("invalid period %d", period)
+		}
+	}
+
+	for _, input := range idc.Inputs {
+		known := false
+		for _, prefix := range prefixes {
+			known = known || strings.HasPrefix(input, prefix)
+		}
+
+		if !known {
+			return fmt.Errorf("unknown input %s", input)
+		}
+	}
+
+	return nil
+}
+
+// variables mainLoop sets for the watchers of a tradingpair and for all
+// watchers
+var (
+	pairNames   = []string{"coin", "currency", "interval", "length", "open", "high", "low", "close", "vol"}
+	scriptNames = []string{"pair_memory", "memory", "alert"}
+)
+
+// knownNames reports names that are in names or start with one of prefixes
+func knownNames(names []string, prefixes []string) func(string) bool {
+	return func(name string) bool {
+		for _, n := range names {
+			if name == n {
+				return true
 			}
+		}
 
-			// increase notification counter
-			cache[key{"global", w.Name}]++
-		} else {
-			// reset cache
-			cache[key{"global", w.Name}] = 0
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// compile loads and compiles the script of a watcher, known reports the
+// variables mainLoop sets for it
+func (w *watcher) compile(dir string, known func(string) bool) error {
+	var err error
+
+	if w.Severity != "" && !notifiers.ValidSeverity(w.Severity) {
+		return fmt.Errorf("unknown severity %q", w.Severity)
+	}
+
+	w.policy = alerts.Policy{MinFirings: w.MinFirings, RepeatCycles: w.Repeat, Resolved: w.Resolved}
+
+	if w.Cooldown != "" {
+		if w.policy.Cooldown, err = time.ParseDuration(w.Cooldown); err != nil {
+			return err
+		}
+	}
+
+	if w.RepeatEvery != "" {
+		if w.policy.RepeatEvery, err = time.ParseDuration(w.RepeatEvery); err != nil {
+			return err
+		}
+	}
+
+	if w.LuaFile != "" {
+		if w.Lua, err = readScript(dir, w.LuaFile); err != nil {
+			return err
+		}
+	}
+
+	if w.Lua != "" {
+		w.luaChunk, err = ss.CompileLua(w.Name, w.Lua)
+	} else if w.Starlark != "" {
+		w.starlarkProgram, err = ss.CompileStarlark(w.Name, w.Starlark, known)
+	} else if w.Expr != "" {
+		w.exprProgram, err = ss.CompileExpr(w.Expr)
+	}
+
+	return err
+}
+
+func compileScripts(dir string) {
+	for i := range config.Tradingpairs {
+		t := &config.Tradingpairs[i]
+
+		for j := range t.Indicators {
+			if err := t.Indicators[j].compile(dir); err != nil {
+				log.Fatalf("%s indicator %s: %v", t.Name, t.Indicators[j].Name, err)
+			}
+		}
+
+		// indicator outputs are named after the indicator plus a label
+		var indicators []string
+		for _, idc := range t.Indicators {
+			indicators = append(indicators, idc.Name)
+		}
+
+		known := knownNames(append(pairNames, scriptNames...), indicators)
+		for j := range t.Watchers {
+			if err := t.Watchers[j].compile(dir, known); err != nil {
+				log.Fatalf("%s watcher %s: %v", t.Name, t.Watchers[j].Name, err)
+			}
+		}
+	}
+
+	// global indicators use series of tradingpairs and earlier indicators
+	var prefixes []string
+	for _, t := range config.Tradingpairs {
+		prefixes = append(prefixes, t.Slug+"_")
+	}
+
+	for i := range config.Indicators {
+		idc := &config.Indicators[i]
+
+		err := idc.compile(dir)
+		if err == nil {
+			err = idc.checkInputs(prefixes)
+		}
+
+		if err != nil {
+			log.Fatalf("indicator %s: %v", idc.Name, err)
+		}
+
+		prefixes = append(prefixes, idc.Name)
+	}
+
+	known := knownNames(scriptNames, prefixes)
+	for i := range config.Watchers {
+		if err := config.Watchers[i].compile(dir, known); err != nil {
+			log.Fatalf("watcher %s: %v", config.Watchers[i].Name, err)
 		}
 	}
 }
@@ -471,6 +1125,18 @@ func loadConfig(file string) {
 	if err != nil {
 		log.Fatal(err)
 	}
+
+	// expand templates before compiling the watchers they create
+	if err := applyTemplates(); err != nil {
+		log.Fatal(err)
+	}
+
+	// script files are relative to the config file
+	dir := filepath.Dir(file)
+	ss.LuaPath = luaPath(dir, config.LuaPath)
+
+	// compile scripts once, reporting syntax errors at startup
+	compileScripts(dir)
 }
 
 func main() {
@@ -481,8 +1147,26 @@ func main() {
 		loadConfig("config.yaml")
 	}
 
-	// notification cache
-	cache = make(map[key]uint64)
+	// lua execution limit
+	if timeout, err := time.ParseDuration(config.LuaTimeout); err == nil {
+		ss.LuaTimeout = timeout
+	}
+
+	// notifiers
+	setupNotifiers()
+
+	// notification cache, kept across restarts
+	cache = make(map[key]*alerts.State)
+	loadAlerts()
+
+	// watcher error counters
+	watcherErrors = make(map[key]*watcherError)
+
+	// persistent script memory
+	loadMemory()
+
+	// incremental indicator state
+	streams = make(map[streamKey]*stream)
 
 	// notification and result channels
 	notifications := make(chan notification)
@@ -512,8 +1196,11 @@ func main() {
 	for {
 		select {
 		case n := <-notifications:
-			go sendNotification(n)
+			sendNotification(n)
 		case r := <-results:
+			// the cycle ended
+			flushNotifiers()
+
 			if config.Verbose {
 				fmt.Printf("Results: %v\n", r)
 			}
@@ -0,0 +1,21 @@
+package notifiers
+
+import "fmt"
+
+// Console prints notifications to stdout
+type Console struct {
+	Format string
+}
+
+// Init sets the message format
+func (c *Console) Init(cfg Config) error {
+	c.Format = format(cfg.Format)
+
+	return nil
+}
+
+// Send prints a notification
+func (c *Console) Send(n Notification) error {
+	_, err := fmt.Printf("%v---\n", n.Format(c.Format))
+	return err
+}
@@ -0,0 +1,91 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Discord posts notifications to a Discord webhook
+type Discord struct {
+	URL    string
+	Format string
+	client *http.Client
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color"`
+	Fields      []discordField `json:"fields,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+}
+
+// Init sets the webhook url
+func (d *Discord) Init(cfg Config) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	d.URL = cfg.URL
+	d.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	d.client = client
+
+	return nil
+}
+
+// Send posts a notification as embed with its values as fields
+func (d *Discord) Send(n Notification) error {
+	embed := discordEmbed{
+		Title: title(n),
+		Color: color(n),
+	}
+
+	if d.Format == "long" && n.Code != "" {
+		embed.Description = "```\n" + n.Code + "\n```"
+	}
+
+	// embeds are limited to 25 fields
+	for _, f := range fields(n) {
+		if len(embed.Fields) == 25 {
+			break
+		}
+		embed.Fields = append(embed.Fields, discordField{f.name, f.value, true})
+	}
+
+	footer := timestamp(n)
+	if len(n.Tags) > 0 {
+		footer += " | " + strings.Join(n.Tags, ", ")
+	}
+
+	if footer != "" {
+		embed.Footer = &discordFooter{footer}
+	}
+
+	body, err := json.Marshal(discordMessage{Embeds: []discordEmbed{embed}})
+	if err != nil {
+		return err
+	}
+
+	return post(d.client, "discord", d.URL, nil, body)
+}
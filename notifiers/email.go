@@ -0,0 +1,322 @@
+package notifiers
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email sends notifications via SMTP, all notifications of a cycle are
+// sent in one email
+type Email struct {
+	Server   string
+	Username string
+	Password string
+	From     string
+	To       []string
+	TLS      string
+	Format   string
+	Timeout  time.Duration
+
+	mutex   sync.Mutex
+	pending []Notification
+}
+
+// maxPending limits the notifications collected while the server is down
+const maxPending = 1000
+
+var emailTemplate = template.Must(template.New("email").Parse(`<html><body>
+{{range .}}<div style="border-left: 4px solid {{.Color}}; padding-left: 8px; margin-bottom: 16px">
+<h3>{{.Title}}</h3>
+{{if .Fields}}<table>{{range .Fields}}<tr><td><b>{{.Name}}</b></td><td>{{.Value}}</td></tr>{{end}}</table>{{end}}
+{{if .Code}}<pre>{{.Code}}</pre>{{end}}
+<small>{{.Timestamp}}</small>
+</div>
+{{end}}</body></html>
+`))
+
+type emailField struct {
+	Name, Value string
+}
+
+type emailEntry struct {
+	Title     string
+	Color     string
+	Fields    []emailField
+	Code      string
+	Timestamp string
+}
+
+// Init sets the server and addresses, tls is "starttls" (default), "tls"
+// for implicit TLS or "none"
+func (e *Email) Init(cfg Config) error {
+	if cfg.Server == "" || cfg.Sender == "" || cfg.Recipient == "" {
+		return errors.New("server, sender and recipient are required")
+	}
+
+	if _, _, err := net.SplitHostPort(cfg.Server); err != nil {
+		return err
+	}
+
+	e.Server = cfg.Server
+	e.Username = cfg.Username
+	e.Password = cfg.Auth
+	e.From = cfg.Sender
+	e.Format = format(cfg.Format)
+
+	for _, to := range strings.Split(cfg.Recipient, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			e.To = append(e.To, to)
+		}
+	}
+
+	switch cfg.TLS {
+	case "":
+		e.TLS = "starttls"
+	case "starttls", "tls", "none":
+		e.TLS = cfg.TLS
+	default:
+		return fmt.Errorf("unknown tls mode %q", cfg.TLS)
+	}
+
+	e.Timeout = 10 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		e.Timeout = d
+	}
+
+	return nil
+}
+
+// Send queues a notification until the end of the cycle
+func (e *Email) Send(n Notification) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if len(e.pending) >= maxPending {
+		return Permanent(errors.New("email: too many pending notifications"))
+	}
+
+	e.pending = append(e.pending, n)
+
+	return nil
+}
+
+// Flush sends the queued notifications in one email, on failure they
+// are kept for the next attempt
+func (e *Email) Flush() error {
+	e.mutex.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.mutex.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	message, err := e.message(batch)
+	if err == nil {
+		err = e.send(message)
+	} else {
+		err = Permanent(err)
+	}
+
+	if err != nil {
+		e.mutex.Lock()
+		e.pending = append(batch, e.pending...)
+		e.mutex.Unlock()
+	}
+
+	return err
+}
+
+// Drop removes the queued notifications
+func (e *Email) Drop() []Notification {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	batch := e.pending
+	e.pending = nil
+
+	return batch
+}
+
+// subject is the title of a single notification or a summary of the batch
+func subject(batch []Notification) string {
+	if len(batch) == 1 {
+		return title(batch[0])
+	}
+
+	return fmt.Sprintf("%d notifications: %s", len(batch), title(batch[0]))
+}
+
+// message builds a multipart message with a plain text and a HTML body
+func (e *Email) message(batch []Notification) ([]byte, error) {
+	var text strings.Builder
+	entries := make([]emailEntry, len(batch))
+
+	for i, n := range batch {
+		if i > 0 {
+			text.WriteString("\n---\n\n")
+		}
+		text.WriteString(n.Format(e.Format))
+
+		entry := emailEntry{
+			Title:     title(n),
+			Color:     fmt.Sprintf("#%06x", color(n)),
+			Timestamp: timestamp(n),
+		}
+
+		for _, f := range fields(n) {
+			entry.Fields = append(entry.Fields, emailField{f.name, f.value})
+		}
+
+		if e.Format == "long" {
+			entry.Code = n.Code
+		}
+
+		entries[i] = entry
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		render      func(w *quotedprintable.Writer) error
+	}{
+		{"text/plain", func(w *quotedprintable.Writer) error {
+			_, err := w.Write([]byte(text.String()))
+			return err
+		}},
+		{"text/html", func(w *quotedprintable.Writer) error {
+			return emailTemplate.Execute(w, entries)
+		}},
+	}
+
+	for _, p := range parts {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(part)
+		if err := p.render(qp); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	header := []string{
+		"From: " + e.From,
+		"To: " + strings.Join(e.To, ", "),
+		"Subject: " + mimeHeader(subject(batch)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + writer.Boundary(),
+	}
+
+	message.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+	message.Write(body.Bytes())
+
+	return message.Bytes(), nil
+}
+
+// mimeHeader encodes a header value and removes line breaks
+func mimeHeader(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return mime.QEncoding.Encode("utf-8", s)
+}
+
+// send delivers a message to the server
+func (e *Email) send(message []byte) error {
+	host, _, _ := net.SplitHostPort(e.Server)
+	tlsConfig := &tls.Config{ServerName: host}
+	dialer := &net.Dialer{Timeout: e.Timeout}
+
+	var conn net.Conn
+	var err error
+	if e.TLS == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", e.Server, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", e.Server)
+	}
+	if err != nil {
+		return err
+	}
+
+	// limit the whole session, not only the connect
+	_ = conn.SetDeadline(time.Now().Add(e.Timeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if e.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+
+	if e.Username != "" {
+		auth := smtp.PlainAuth("", e.Username, e.Password, host)
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(e.From); err != nil {
+		return err
+	}
+
+	for _, to := range e.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,193 @@
+package notifiers
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpServer is a minimal SMTP stand-in that records the messages it
+// receives and rejects them while fail is set
+type smtpServer struct {
+	listener net.Listener
+
+	mutex    sync.Mutex
+	fail     bool
+	messages []string
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &smtpServer{listener: listener}
+	go s.serve()
+
+	return s
+}
+
+func (s *smtpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost")
+
+	var data strings.Builder
+	inData := false
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+
+				s.mutex.Lock()
+				s.messages = append(s.messages, data.String())
+				s.mutex.Unlock()
+
+				reply("250 queued")
+				continue
+			}
+
+			data.WriteString(line)
+			continue
+		}
+
+		command := strings.ToUpper(strings.TrimSpace(line))
+
+		s.mutex.Lock()
+		fail := s.fail
+		s.mutex.Unlock()
+
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "MAIL") && fail:
+			reply("451 try again later")
+		case command == "DATA":
+			inData = true
+			data.Reset()
+			reply("354 end with .")
+		case command == "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpServer) setFail(fail bool) {
+	s.mutex.Lock()
+	s.fail = fail
+	s.mutex.Unlock()
+}
+
+func (s *smtpServer) received() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]string(nil), s.messages...)
+}
+
+func newEmail(t *testing.T, server *smtpServer) *Email {
+	n, err := New(Config{
+		Type:      "email",
+		Server:    server.listener.Addr().String(),
+		Sender:    "watcher@example.com",
+		Recipient: "a@example.com, b@example.com",
+		TLS:       "none",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return n.(*Email)
+}
+
+func TestEmailBatch(t *testing.T) {
+	server := newSMTPServer(t)
+	defer server.listener.Close()
+
+	email := newEmail(t, server)
+
+	email.Send(Notification{Source: "BTC", Message: "RSI low", Values: map[string]float64{"rsi": 25.5}})
+	email.Send(Notification{Source: "ETH", Message: "RSI high"})
+
+	if err := email.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d emails, want 1", len(messages))
+	}
+
+	message := messages[0]
+	for _, want := range []string{"Subject: 2 notifications: BTC: RSI low", "ETH", "Rsi", "25.50", "text/html"} {
+		if !strings.Contains(message, want) {
+			t.Errorf("email does not contain %q:\n%s", want, message)
+		}
+	}
+
+	// nothing left to send
+	if err := email.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(server.received()) != 1 {
+		t.Error("empty flush sent an email")
+	}
+}
+
+func TestEmailKeepOnFailure(t *testing.T) {
+	server := newSMTPServer(t)
+	defer server.listener.Close()
+
+	email := newEmail(t, server)
+	email.Send(Notification{Source: "BTC", Message: "first"})
+
+	server.setFail(true)
+	if err := email.Flush(); err == nil {
+		t.Fatal("flush succeeded while the server rejects mail")
+	}
+
+	// the failed batch is sent with the next one
+	server.setFail(false)
+	email.Send(Notification{Source: "ETH", Message: "second"})
+
+	if err := email.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.received()
+	if len(messages) != 1 || !strings.Contains(messages[0], "first") || !strings.Contains(messages[0], "second") {
+		t.Fatalf("got %q, want one email with both notifications", messages)
+	}
+
+	// dropped notifications are returned
+	email.Send(Notification{Message: "third"})
+	if dropped := email.Drop(); len(dropped) != 1 || dropped[0].Message != "third" {
+		t.Errorf("dropped %v, want the third notification", dropped)
+	}
+}
@@ -0,0 +1,107 @@
+package notifiers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exec runs a command for each notification, the notification is passed
+// as JSON on stdin and as CW_* environment variables
+type Exec struct {
+	Command string
+	Args    []string
+	Timeout time.Duration
+}
+
+var envName = regexp.MustCompile(`[^A-Z0-9_]`)
+
+// Init sets the command and the timeout, 10 seconds by default
+func (e *Exec) Init(cfg Config) error {
+	if cfg.Command == "" {
+		return errors.New("command is required")
+	}
+
+	e.Command = cfg.Command
+	e.Args = cfg.Args
+
+	e.Timeout = 10 * time.Second
+	if cfg.Timeout != "" {
+		d, err := time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		e.Timeout = d
+	}
+
+	return nil
+}
+
+// Send runs the command, stderr is logged and returned on failure
+func (e *Exec) Send(n Notification) error {
+	input, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, e.Command, e.Args...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), environment(n)...)
+
+	err = cmd.Run()
+	output := strings.TrimSpace(stderr.String())
+
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timeout after %v", e.Timeout)
+	}
+
+	if err != nil {
+		if output != "" {
+			return fmt.Errorf("%s: %v: %s", e.Command, err, output)
+		}
+		return fmt.Errorf("%s: %v", e.Command, err)
+	}
+
+	if output != "" {
+		log.Printf("%s: %s", e.Command, output)
+	}
+
+	return nil
+}
+
+// environment returns the notification as variables, values are passed
+// as CW_VALUE_<NAME>
+func environment(n Notification) []string {
+	env := []string{
+		"CW_KIND=" + n.Kind,
+		"CW_TIMESTAMP=" + n.Timestamp.Format(time.RFC3339),
+		"CW_SOURCE=" + n.Source,
+		"CW_TRADINGPAIR=" + n.Tradingpair,
+		"CW_WATCHER=" + n.Watcher,
+		"CW_MESSAGE=" + n.Message,
+		"CW_SEVERITY=" + n.Severity,
+		"CW_TAGS=" + strings.Join(n.Tags, ","),
+	}
+
+	for k, v := range n.Values {
+		name := envName.ReplaceAllString(strings.ToUpper(k), "_")
+		env = append(env, "CW_VALUE_"+name+"="+strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	return env
+}
@@ -0,0 +1,101 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File appends notifications as JSON lines to a log file, which is rotated
+// to path.1, path.2, ... when it exceeds the maximum size
+type File struct {
+	Path     string
+	MaxSize  int64
+	MaxFiles int
+
+	mutex sync.Mutex
+}
+
+// record is a log line, the timestamp is written in RFC3339
+type record struct {
+	Notification
+	Timestamp string `json:"timestamp"`
+}
+
+// Init sets the path, logs are rotated at 10 MB and 5 are kept by default
+func (f *File) Init(cfg Config) error {
+	if cfg.Path == "" {
+		return errors.New("path is required")
+	}
+
+	f.Path = cfg.Path
+
+	f.MaxSize = cfg.MaxSize
+	if f.MaxSize <= 0 {
+		f.MaxSize = 10 << 20
+	}
+
+	f.MaxFiles = cfg.MaxFiles
+	if f.MaxFiles <= 0 {
+		f.MaxFiles = 5
+	}
+
+	return nil
+}
+
+// Send appends a notification to the log
+func (f *File) Send(n Notification) error {
+	line, err := json.Marshal(record{n, n.Timestamp.Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if err := f.rotate(int64(len(line))); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(f.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(line); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// rotate moves the log away if the next line would exceed the maximum size
+func (f *File) rotate(size int64) error {
+	info, err := os.Stat(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.Size() == 0 || info.Size()+size <= f.MaxSize {
+		return nil
+	}
+
+	for i := f.MaxFiles - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", f.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", f.Path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return os.Rename(f.Path, f.Path+".1")
+}
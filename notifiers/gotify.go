@@ -0,0 +1,60 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Gotify sends notifications to a Gotify server
+type Gotify struct {
+	URL    string
+	Token  string
+	Format string
+	client *http.Client
+}
+
+// gotify priorities from 0 to 10, 8 and higher are shown as popup
+var gotifyPriority = map[int]int{low: 2, normal: 4, high: 6, urgent: 8}
+
+type gotifyMessage struct {
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Priority int    `json:"priority"`
+}
+
+// Init sets the server url and the application token
+func (g *Gotify) Init(cfg Config) error {
+	if cfg.URL == "" || cfg.Auth == "" {
+		return errors.New("url and auth are required")
+	}
+
+	g.URL = strings.TrimSuffix(cfg.URL, "/") + "/message"
+	g.Token = cfg.Auth
+	g.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	g.client = client
+
+	return nil
+}
+
+// Send sends a notification
+func (g *Gotify) Send(n Notification) error {
+	body, err := json.Marshal(gotifyMessage{
+		Title:    title(n),
+		Message:  n.Format(format(g.Format)),
+		Priority: gotifyPriority[urgency(n)],
+	})
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]string{"X-Gotify-Key": g.Token}
+
+	return post(g.client, "gotify", g.URL, headers, body)
+}
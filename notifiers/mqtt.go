@@ -0,0 +1,147 @@
+package notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+var topicInvalid = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// topicSegment makes a name usable as a single topic level, it is
+// lowercased and other characters than a-z, 0-9, _ and - are replaced
+func topicSegment(name string) string {
+	return topicInvalid.ReplaceAllString(strings.ToLower(name), "_")
+}
+
+// MQTT publishes notifications to a broker
+type MQTT struct {
+	Topic    *template.Template
+	Template *template.Template
+	QoS      byte
+	Retain   bool
+	Timeout  time.Duration
+	client   mqtt.Client
+}
+
+// the default topic is cryptowatcher/<slug>/<watcher>, updates and global
+// watchers use the kind and "global" instead. Templates get the slug and
+// watcher as topic segments.
+const mqttTopic = `cryptowatcher/{{if .Tradingpair}}{{.Tradingpair}}{{else}}global{{end}}/{{if .Watcher}}{{.Watcher}}{{else}}{{.Kind}}{{end}}`
+
+// Init parses the templates and connects to the broker, e.g.
+// tcp://localhost:1883, the connection is retried in the background
+func (m *MQTT) Init(cfg Config) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	if cfg.QoS > 2 {
+		return fmt.Errorf("invalid qos %d", cfg.QoS)
+	}
+
+	topic := cfg.Topic
+	if topic == "" {
+		topic = mqttTopic
+	}
+
+	var err error
+	m.Topic, err = template.New("topic").Funcs(templateFuncs).Parse(topic)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Template != "" {
+		m.Template, err = template.New("payload").Funcs(templateFuncs).Parse(cfg.Template)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.QoS = cfg.QoS
+	m.Retain = cfg.Retain
+
+	m.Timeout = 10 * time.Second
+	if cfg.Timeout != "" {
+		m.Timeout, err = time.ParseDuration(cfg.Timeout)
+		if err != nil {
+			return err
+		}
+	}
+
+	clientID := cfg.ClientID
+	if clientID == "" {
+		clientID = "cryptowatcher"
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(cfg.URL).
+		SetClientID(clientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Auth).
+		SetConnectTimeout(m.Timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true)
+
+	m.client = mqtt.NewClient(options)
+
+	// messages published while connecting are only sent once connected,
+	// so wait for the first connection
+	if !m.client.Connect().WaitTimeout(m.Timeout) {
+		log.Printf("mqtt: %s not reachable, retrying in the background", cfg.URL)
+	}
+
+	return nil
+}
+
+// Send publishes a notification as JSON or rendered with the template
+func (m *MQTT) Send(n Notification) error {
+	var topic, payload bytes.Buffer
+
+	// names are used as topic levels
+	levels := n
+	levels.Tradingpair = topicSegment(n.Tradingpair)
+	levels.Watcher = topicSegment(n.Watcher)
+
+	if err := m.Topic.Execute(&topic, levels); err != nil {
+		return Permanent(fmt.Errorf("mqtt: %v", err))
+	}
+
+	if m.Template != nil {
+		if err := m.Template.Execute(&payload, n); err != nil {
+			return fmt.Errorf("mqtt: %v", err)
+		}
+	} else if err := json.NewEncoder(&payload).Encode(n); err != nil {
+		return fmt.Errorf("mqtt: %v", err)
+	}
+
+	name := strings.TrimSpace(topic.String())
+	if name == "" || strings.ContainsAny(name, "+#") {
+		return Permanent(fmt.Errorf("mqtt: invalid topic %q", name))
+	}
+
+	// paho stores messages published while connecting and sends them
+	// later, which duplicates them when the queue retries
+	if !m.client.IsConnectionOpen() {
+		return errors.New("mqtt: not connected")
+	}
+
+	token := m.client.Publish(name, m.QoS, m.Retain, payload.Bytes())
+	if !token.WaitTimeout(m.Timeout) {
+		return fmt.Errorf("mqtt: publishing to %s timed out", name)
+	}
+
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("mqtt: %v", err)
+	}
+
+	return nil
+}
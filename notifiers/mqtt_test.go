@@ -0,0 +1,140 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+)
+
+// readPacket reads an MQTT control packet and returns its type and body
+func readPacket(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for {
+		b := make([]byte, 1)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return 0, nil, err
+		}
+
+		length += int(b[0]&127) * multiplier
+		multiplier *= 128
+
+		if b[0]&128 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	_, err := io.ReadFull(r, body)
+
+	return header[0] >> 4, body, err
+}
+
+// mqttBroker accepts one client and returns the topic and payload of the
+// first QoS 0 publish
+func mqttBroker(t *testing.T) (string, <-chan [2]string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := make(chan [2]string, 1)
+
+	go func() {
+		defer listener.Close()
+
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			typ, body, err := readPacket(conn)
+			if err != nil {
+				return
+			}
+
+			switch typ {
+			case 1: // connect
+				conn.Write([]byte{0x20, 2, 0, 0})
+			case 3: // publish
+				n := int(body[0])<<8 | int(body[1])
+				published <- [2]string{string(body[2 : 2+n]), string(body[2+n:])}
+			}
+		}
+	}()
+
+	return "tcp://" + listener.Addr().String(), published
+}
+
+func TestMQTTPublish(t *testing.T) {
+	url, published := mqttBroker(t)
+
+	n, err := New(Config{Type: "mqtt", URL: url, Timeout: "2s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(Notification{Kind: "alert", Tradingpair: "btcusd", Watcher: "RSI Low/1h", Message: "RSI low"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := <-published
+
+	if want := "cryptowatcher/btcusd/rsi_low_1h"; message[0] != want {
+		t.Errorf("topic is %s, want %s", message[0], want)
+	}
+
+	var payload Notification
+	if err := json.Unmarshal([]byte(message[1]), &payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if payload.Watcher != "RSI Low/1h" || payload.Message != "RSI low" {
+		t.Errorf("payload is %+v", payload)
+	}
+}
+
+func TestTopicSegment(t *testing.T) {
+	tests := map[string]string{
+		"rsi_low":   "rsi_low",
+		"RSI Low":   "rsi_low",
+		"a/b":       "a_b",
+		"cross+#":   "cross__",
+		"macd-hist": "macd-hist",
+	}
+
+	for name, want := range tests {
+		if got := topicSegment(name); got != want {
+			t.Errorf("topicSegment(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestMQTTNotConnected(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing listens on the address
+	url := "tcp://" + listener.Addr().String()
+	listener.Close()
+
+	n, err := New(Config{Type: "mqtt", URL: url, Timeout: "100ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(Notification{Kind: "alert", Tradingpair: "btcusd", Watcher: "rsi_low"})
+	if err == nil || IsPermanent(err) {
+		t.Errorf("got %v, want a temporary error", err)
+	}
+}
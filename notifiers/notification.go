@@ -0,0 +1,74 @@
+package notifiers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Notification is a message about an alert, update, error or resolved
+// alert. Timestamp has no monotonic clock reading, so templates print it
+// as "2006-01-02 15:04:05.999999999 -0700 MST" or format it, e.g.
+// {{.Timestamp.Format "Monday, 02-Jan-06 15:04:05 MST"}}.
+type Notification struct {
+	Kind        string             `json:"kind" yaml:"kind"`
+	Timestamp   time.Time          `json:"timestamp" yaml:"timestamp"`
+	Message     string             `json:"message" yaml:"message"`
+	Source      string             `json:"source" yaml:"source"`
+	Tradingpair string             `json:"tradingpair" yaml:"tradingpair"`
+	Watcher     string             `json:"watcher" yaml:"watcher"`
+	Code        string             `json:"code" yaml:"code"`
+	Severity    string             `json:"severity" yaml:"severity"`
+	Tags        []string           `json:"tags" yaml:"tags"`
+	Values      map[string]float64 `json:"values" yaml:"values"`
+}
+
+// Format renders the notification as text, template is one of "short",
+// "normal" and "long"
+func (n Notification) Format(template string) string {
+	message, values := "", ""
+
+	for k, v := range n.Values {
+		if v < 1 {
+			// 0 digits
+			values += fmt.Sprintf("%-12s: %-8.6f\n", strings.Title(k), v)
+		} else if v < 1000 {
+			// three digits
+			values += fmt.Sprintf("%-12s: %-8.2f\n", strings.Title(k), v)
+		} else {
+			// four digits
+			values += fmt.Sprintf("%-12s: %-8.0f\n", strings.Title(k), v)
+		}
+	}
+
+	if n.Source != "" {
+		message += n.Source + "\n"
+	}
+
+	message += n.Message + "\n"
+
+	switch template {
+	case "normal":
+		if values != "" {
+			message += "\n" + values
+		}
+	case "long":
+		if values != "" {
+			message += "\n" + values
+		}
+
+		message += "\n" + n.Code
+	default:
+	}
+
+	return message
+}
+
+// format returns the configured format, "normal" by default
+func format(template string) string {
+	if template == "" {
+		return "normal"
+	}
+
+	return template
+}
@@ -1,45 +1,102 @@
 package notifiers
 
 import (
+	"fmt"
 	"log"
-	"net/http"
-	"net/url"
-	"strings"
 )
 
 // Notifier provides an interface to different notifiers
 type Notifier interface {
-	Init(ID string, Key string)
-	Send(chatID string, text string)
+	Init(cfg Config) error
+	Send(n Notification) error
 }
 
-// Telegram holds authentication state for telegram messaging
-type Telegram struct {
-	BotID  string
-	APIKey string
+// Flusher is implemented by notifiers that collect notifications and
+// deliver them at the end of each cycle
+type Flusher interface {
+	// Flush delivers the collected notifications, on failure they are
+	// kept for the next attempt
+	Flush() error
+	// Drop removes and returns the collected notifications
+	Drop() []Notification
 }
 
-// Init sets the authentication parameters for telegram
-func (t Telegram) Init(ID string, Key string) {
-	t.BotID = ID
-	t.APIKey = Key
-}
+// Config is the configuration of a notifier
+type Config struct {
+	Type      string `json:"type" yaml:"type"`
+	Recipient string `json:"recipient" yaml:"recipient"`
+	Sender    string `json:"sender" yaml:"sender"`
+	Auth      string `json:"auth" yaml:"auth"`
+	Format    string `json:"format" yaml:"format"`
+	Route     Route  `json:"route" yaml:"route"`
+
+	// http based notifiers
+	URL      string            `json:"url" yaml:"url"`
+	Headers  map[string]string `json:"headers" yaml:"headers"`
+	Template string            `json:"template" yaml:"template"`
+	Timeout  string            `json:"timeout" yaml:"timeout"`
+
+	// delivery, failed notifications are retried after backoff, which
+	// doubles on each retry
+	Retries int    `json:"retries" yaml:"retries"`
+	Backoff string `json:"backoff" yaml:"backoff"`
 
-// Send sends a message to a recipient
-func (t Telegram) Send(chatID string, text string) {
-	chatID = url.QueryEscape(chatID)
-	text = url.QueryEscape(text)
+	// email, sender and recipient are the from and to addresses and auth
+	// is the password
+	Server   string `json:"server" yaml:"server"`
+	Username string `json:"username" yaml:"username"`
+	TLS      string `json:"tls" yaml:"tls"`
 
-	link := "https://api.telegram.org/bot{botID}:{apiKey}/sendMessage?chat_id={chatID}&text={text}"
+	// exec
+	Command string   `json:"command" yaml:"command"`
+	Args    []string `json:"args" yaml:"args"`
 
-	link = strings.Replace(link, "{botID}", t.BotID, -1)
-	link = strings.Replace(link, "{apiKey}", t.APIKey, -1)
-	link = strings.Replace(link, "{chatID}", chatID, -1)
-	link = strings.Replace(link, "{text}", text, -1)
+	// file, max size in bytes before the log is rotated and number of
+	// rotated logs to keep
+	Path     string `json:"path" yaml:"path"`
+	MaxSize  int64  `json:"max_size" yaml:"max_size"`
+	MaxFiles int    `json:"max_files" yaml:"max_files"`
 
-	_, err := http.Get(link)
+	// mqtt, the url is the broker and the topic a template
+	Topic    string `json:"topic" yaml:"topic"`
+	QoS      byte   `json:"qos" yaml:"qos"`
+	Retain   bool   `json:"retain" yaml:"retain"`
+	ClientID string `json:"client_id" yaml:"client_id"`
+}
+
+// registry maps notifier types to constructors
+var registry = map[string]func() Notifier{
+	"console":  func() Notifier { return &Console{} },
+	"discord":  func() Notifier { return &Discord{} },
+	"email":    func() Notifier { return &Email{} },
+	"exec":     func() Notifier { return &Exec{} },
+	"file":     func() Notifier { return &File{} },
+	"gotify":   func() Notifier { return &Gotify{} },
+	"mqtt":     func() Notifier { return &MQTT{} },
+	"ntfy":     func() Notifier { return &Ntfy{} },
+	"pushover": func() Notifier { return &Pushover{} },
+	"slack":    func() Notifier { return &Slack{} },
+	"telegram": func() Notifier { return &Telegram{} },
+	"webhook":  func() Notifier { return &Webhook{} },
+}
 
-	if err != nil {
-		log.Fatal(err)
+// New creates and initializes the notifier for a configuration, unknown
+// types print to stdout as before notifiers could be configured
+func New(cfg Config) (Notifier, error) {
+	create, ok := registry[cfg.Type]
+	if !ok {
+		log.Printf("unknown notifier type %q, printing to stdout", cfg.Type)
+		create = registry["console"]
 	}
+
+	if err := cfg.Route.validate(); err != nil {
+		return nil, fmt.Errorf("%s notifier: %v", cfg.Type, err)
+	}
+
+	n := create()
+	if err := n.Init(cfg); err != nil {
+		return nil, fmt.Errorf("%s notifier: %v", cfg.Type, err)
+	}
+
+	return n, nil
 }
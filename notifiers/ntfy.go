@@ -0,0 +1,59 @@
+package notifiers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Ntfy publishes notifications to a ntfy topic
+type Ntfy struct {
+	URL    string
+	Token  string
+	Format string
+	client *http.Client
+}
+
+// ntfy priorities from min (1) to max (5)
+var ntfyPriority = map[int]int{low: 2, normal: 3, high: 4, urgent: 5}
+
+// Init sets the topic url, e.g. https://ntfy.sh/mytopic, and the optional
+// access token
+func (t *Ntfy) Init(cfg Config) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	t.URL = cfg.URL
+	t.Token = cfg.Auth
+	t.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	t.client = client
+
+	return nil
+}
+
+// Send publishes a notification
+func (t *Ntfy) Send(n Notification) error {
+	// line breaks are not allowed in header values
+	headers := map[string]string{
+		"Content-Type": "text/plain; charset=utf-8",
+		"Title":        strings.Join(strings.Fields(title(n)), " "),
+		"Priority":     strconv.Itoa(ntfyPriority[urgency(n)]),
+	}
+
+	if len(n.Tags) > 0 {
+		headers["Tags"] = strings.Join(n.Tags, ",")
+	}
+
+	if t.Token != "" {
+		headers["Authorization"] = "Bearer " + t.Token
+	}
+
+	return post(t.client, "ntfy", t.URL, headers, []byte(n.Format(format(t.Format))))
+}
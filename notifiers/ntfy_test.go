@@ -0,0 +1,30 @@
+package notifiers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNtfyTitle(t *testing.T) {
+	var title string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		title = r.Header.Get("Title")
+	}))
+	defer server.Close()
+
+	n, err := New(Config{Type: "ntfy", URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(Notification{Source: "BTC/USDT 1h", Message: "RSI low\r\nclose  below\tSMA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "BTC/USDT 1h: RSI low close below SMA"; title != want {
+		t.Errorf("title is %q, want %q", title, want)
+	}
+}
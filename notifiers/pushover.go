@@ -0,0 +1,59 @@
+package notifiers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Pushover sends notifications with the Pushover API
+type Pushover struct {
+	URL    string
+	Token  string
+	User   string
+	Format string
+	client *http.Client
+}
+
+// pushover priorities from -2 to 2, 2 needs to be acknowledged and is not
+// used
+var pushoverPriority = map[int]int{low: -1, normal: 0, high: 0, urgent: 1}
+
+// Init sets the application token and the user key
+func (p *Pushover) Init(cfg Config) error {
+	if cfg.Auth == "" || cfg.Recipient == "" {
+		return errors.New("auth and recipient are required")
+	}
+
+	p.URL = cfg.URL
+	if p.URL == "" {
+		p.URL = "https://api.pushover.net/1/messages.json"
+	}
+
+	p.Token = cfg.Auth
+	p.User = cfg.Recipient
+	p.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	p.client = client
+
+	return nil
+}
+
+// Send sends a notification
+func (p *Pushover) Send(n Notification) error {
+	form := url.Values{}
+	form.Set("token", p.Token)
+	form.Set("user", p.User)
+	form.Set("title", title(n))
+	form.Set("message", n.Format(format(p.Format)))
+	form.Set("priority", strconv.Itoa(pushoverPriority[urgency(n)]))
+
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+
+	return post(p.client, "pushover", p.URL, headers, []byte(form.Encode()))
+}
@@ -0,0 +1,155 @@
+package notifiers
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// permanentError is a delivery error that is not retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+// Permanent marks an error that retrying will not fix, e.g. a rejected
+// request
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent checks if a delivery error is permanent
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Result is the outcome of a delivery, Notification is nil for a
+// successful flush. The notifications of a failed flush are reported
+// one by one.
+type Result struct {
+	Notifier     string
+	Notification *Notification
+	Attempts     int
+	Err          error
+}
+
+// Queue delivers notifications to a notifier in the background, failed
+// deliveries are retried with exponential backoff
+type Queue struct {
+	Name     string
+	Notifier Notifier
+	Retries  int
+	Backoff  time.Duration
+	// Done is called with the outcome of each delivery
+	Done func(Result)
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+	jobs  []*Notification
+}
+
+// NewQueue creates a queue for a notifier and starts delivering, retries
+// default to 3 with 1 second backoff and negative retries disable them
+func NewQueue(name string, notifier Notifier, cfg Config, done func(Result)) (*Queue, error) {
+	q := &Queue{
+		Name:     name,
+		Notifier: notifier,
+		Retries:  cfg.Retries,
+		Backoff:  time.Second,
+		Done:     done,
+	}
+
+	if q.Retries == 0 {
+		q.Retries = 3
+	} else if q.Retries < 0 {
+		q.Retries = 0
+	}
+
+	if cfg.Backoff != "" {
+		d, err := time.ParseDuration(cfg.Backoff)
+		if err != nil {
+			return nil, err
+		}
+		q.Backoff = d
+	}
+
+	q.cond = sync.NewCond(&q.mutex)
+	go q.run()
+
+	return q, nil
+}
+
+// Send queues a notification, it never blocks
+func (q *Queue) Send(n Notification) {
+	q.push(&n)
+}
+
+// Flush queues a flush after the notifications sent so far, it does
+// nothing if the notifier is no Flusher
+func (q *Queue) Flush() {
+	if _, ok := q.Notifier.(Flusher); ok {
+		q.push(nil)
+	}
+}
+
+func (q *Queue) push(job *Notification) {
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.mutex.Unlock()
+
+	q.cond.Signal()
+}
+
+func (q *Queue) run() {
+	for {
+		q.mutex.Lock()
+		for len(q.jobs) == 0 {
+			q.cond.Wait()
+		}
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		q.mutex.Unlock()
+
+		q.deliver(job)
+	}
+}
+
+func (q *Queue) deliver(job *Notification) {
+	result := Result{Notifier: q.Name, Notification: job}
+	delay := q.Backoff
+
+	for result.Attempts <= q.Retries {
+		if result.Attempts > 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		result.Attempts++
+
+		if job == nil {
+			result.Err = q.Notifier.(Flusher).Flush()
+		} else {
+			result.Err = q.Notifier.Send(*job)
+		}
+
+		if result.Err == nil || IsPermanent(result.Err) {
+			break
+		}
+	}
+
+	if q.Done == nil {
+		return
+	}
+
+	if job == nil && result.Err != nil {
+		for _, n := range q.Notifier.(Flusher).Drop() {
+			n := n
+			q.Done(Result{q.Name, &n, result.Attempts, result.Err})
+		}
+		return
+	}
+
+	q.Done(result)
+}
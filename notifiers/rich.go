@@ -0,0 +1,104 @@
+package notifiers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// color returns the color of a notification as RGB value, alerts are
+// colored by severity
+func color(n Notification) int {
+	switch n.Kind {
+	case "resolved":
+		return 0x2e7d32
+	case "error":
+		return 0x6a1b9a
+	case "update":
+		return 0x607d8b
+	}
+
+	switch strings.ToLower(n.Severity) {
+	case "critical":
+		return 0xd32f2f
+	case "warning":
+		return 0xf9a825
+	}
+
+	return 0x1976d2
+}
+
+// timestamp formats the time of a notification for display
+func timestamp(n Notification) string {
+	if n.Timestamp.IsZero() {
+		return ""
+	}
+
+	return n.Timestamp.Format(time.RFC850)
+}
+
+// Urgency levels of notifications
+const (
+	low = iota
+	normal
+	high
+	urgent
+)
+
+// urgency maps the kind and severity of a notification to a level
+func urgency(n Notification) int {
+	switch n.Kind {
+	case "resolved", "update":
+		return low
+	case "error":
+		return high
+	}
+
+	switch strings.ToLower(n.Severity) {
+	case "critical":
+		return urgent
+	case "warning":
+		return high
+	}
+
+	return normal
+}
+
+// title returns the first line of a rich notification
+func title(n Notification) string {
+	if n.Source == "" {
+		return n.Message
+	}
+
+	return n.Source + ": " + n.Message
+}
+
+type field struct {
+	name, value string
+}
+
+// fields returns the values sorted by name with the precision of Format
+func fields(n Notification) []field {
+	keys := make([]string, 0, len(n.Values))
+	for k := range n.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]field, len(keys))
+	for i, k := range keys {
+		v := n.Values[k]
+
+		value := fmt.Sprintf("%.0f", v)
+		if v < 1 {
+			value = fmt.Sprintf("%.6f", v)
+		} else if v < 1000 {
+			value = fmt.Sprintf("%.2f", v)
+		}
+
+		result[i] = field{strings.Title(k), value}
+	}
+
+	return result
+}
@@ -0,0 +1,77 @@
+package notifiers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Route selects the notifications sent to a notifier. Empty fields match
+// all notifications, lists match if any of their entries matches.
+type Route struct {
+	Tradingpairs []string `json:"tradingpairs" yaml:"tradingpairs"`
+	Watchers     []string `json:"watchers" yaml:"watchers"`
+	Tags         []string `json:"tags" yaml:"tags"`
+	Kinds        []string `json:"kinds" yaml:"kinds"`
+	// Severity is the minimum severity, notifications without severity
+	// are info
+	Severity string `json:"severity" yaml:"severity"`
+}
+
+var severities = map[string]int{"info": 0, "warning": 1, "critical": 2}
+
+// ValidSeverity checks if a severity is info, warning or critical
+func ValidSeverity(severity string) bool {
+	_, ok := severities[strings.ToLower(severity)]
+	return ok
+}
+
+func (r Route) validate() error {
+	if r.Severity != "" && !ValidSeverity(r.Severity) {
+		return fmt.Errorf("unknown severity %q", r.Severity)
+	}
+
+	return nil
+}
+
+// Matches checks if a notification is selected by the route
+func (r Route) Matches(n Notification) bool {
+	if !contains(r.Tradingpairs, n.Tradingpair) {
+		return false
+	}
+
+	if !contains(r.Watchers, n.Watcher) {
+		return false
+	}
+
+	if !contains(r.Kinds, n.Kind) {
+		return false
+	}
+
+	if len(r.Tags) > 0 {
+		tagged := false
+		for _, tag := range n.Tags {
+			tagged = tagged || contains(r.Tags, tag)
+		}
+
+		if !tagged {
+			return false
+		}
+	}
+
+	return severities[strings.ToLower(n.Severity)] >= severities[strings.ToLower(r.Severity)]
+}
+
+// contains is true for an empty list or if the list contains s
+func contains(list []string, s string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, entry := range list {
+		if entry == s {
+			return true
+		}
+	}
+
+	return false
+}
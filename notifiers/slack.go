@@ -0,0 +1,111 @@
+package notifiers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Slack posts notifications to a Slack incoming webhook
+type Slack struct {
+	URL    string
+	Format string
+	client *http.Client
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackBlock struct {
+	Type     string      `json:"type"`
+	Text     *slackText  `json:"text,omitempty"`
+	Fields   []slackText `json:"fields,omitempty"`
+	Elements []slackText `json:"elements,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Blocks []slackBlock `json:"blocks"`
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// Init sets the webhook url
+func (s *Slack) Init(cfg Config) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	s.URL = cfg.URL
+	s.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	s.client = client
+
+	return nil
+}
+
+// Send posts a notification with its values as fields
+func (s *Slack) Send(n Notification) error {
+	blocks := []slackBlock{{
+		Type: "section",
+		Text: &slackText{"mrkdwn", "*" + title(n) + "*"},
+	}}
+
+	// sections are limited to 10 fields
+	var section []slackText
+	for _, f := range fields(n) {
+		section = append(section, slackText{"mrkdwn", "*" + f.name + "*\n" + f.value})
+
+		if len(section) == 10 {
+			blocks = append(blocks, slackBlock{Type: "section", Fields: section})
+			section = nil
+		}
+	}
+
+	if len(section) > 0 {
+		blocks = append(blocks, slackBlock{Type: "section", Fields: section})
+	}
+
+	if s.Format == "long" && n.Code != "" {
+		blocks = append(blocks, slackBlock{
+			Type: "section",
+			Text: &slackText{"mrkdwn", "```" + n.Code + "```"},
+		})
+	}
+
+	context := timestamp(n)
+	if len(n.Tags) > 0 {
+		context += " | " + strings.Join(n.Tags, ", ")
+	}
+
+	if context != "" {
+		blocks = append(blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{"mrkdwn", context}},
+		})
+	}
+
+	body, err := json.Marshal(slackMessage{
+		Text: title(n),
+		Attachments: []slackAttachment{{
+			Color:  fmt.Sprintf("#%06x", color(n)),
+			Blocks: blocks,
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return post(s.client, "slack", s.URL, nil, body)
+}
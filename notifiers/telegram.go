@@ -0,0 +1,60 @@
+package notifiers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Telegram holds authentication state for telegram messaging
+type Telegram struct {
+	BotID  string
+	APIKey string
+	ChatID string
+	Format string
+	client *http.Client
+}
+
+// Init sets the authentication parameters for telegram
+func (t *Telegram) Init(cfg Config) error {
+	if cfg.Sender == "" || cfg.Auth == "" || cfg.Recipient == "" {
+		return errors.New("sender, auth and recipient are required")
+	}
+
+	t.BotID = cfg.Sender
+	t.APIKey = cfg.Auth
+	t.ChatID = cfg.Recipient
+	t.Format = cfg.Format
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	t.client = client
+
+	return nil
+}
+
+// Send sends a notification to the recipient
+func (t *Telegram) Send(n Notification) error {
+	chatID := url.QueryEscape(t.ChatID)
+	text := url.QueryEscape(n.Format(t.Format))
+
+	link := "https://api.telegram.org/bot{botID}:{apiKey}/sendMessage?chat_id={chatID}&text={text}"
+
+	link = strings.Replace(link, "{botID}", t.BotID, -1)
+	link = strings.Replace(link, "{apiKey}", t.APIKey, -1)
+	link = strings.Replace(link, "{chatID}", chatID, -1)
+	link = strings.Replace(link, "{text}", text, -1)
+
+	resp, err := t.client.Get(link)
+	if err != nil {
+		// drop the link, it contains the api key
+		return fmt.Errorf("telegram: %v", stripURL(err))
+	}
+	defer resp.Body.Close()
+
+	return statusError("telegram", resp)
+}
@@ -0,0 +1,137 @@
+package notifiers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+)
+
+// Webhook posts notifications to a url
+type Webhook struct {
+	URL      string
+	Headers  map[string]string
+	Template *template.Template
+	client   *http.Client
+}
+
+// templateFuncs are available in body templates, json encodes a value,
+// e.g. {"text": {{json .Message}}}
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// Init parses the body template, without a template the notification is
+// sent as JSON
+func (w *Webhook) Init(cfg Config) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+
+	w.URL = cfg.URL
+	w.Headers = cfg.Headers
+
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(templateFuncs).Parse(cfg.Template)
+		if err != nil {
+			return err
+		}
+		w.Template = tmpl
+	}
+
+	client, err := httpClient(cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	w.client = client
+
+	return nil
+}
+
+// Send posts a notification
+func (w *Webhook) Send(n Notification) error {
+	var body bytes.Buffer
+
+	if w.Template != nil {
+		if err := w.Template.Execute(&body, n); err != nil {
+			return fmt.Errorf("webhook: %v", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(n); err != nil {
+		return fmt.Errorf("webhook: %v", err)
+	}
+
+	return post(w.client, "webhook", w.URL, w.Headers, body.Bytes())
+}
+
+// httpClient creates a client with a timeout, 10 seconds by default
+func httpClient(timeout string) (*http.Client, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, err
+		}
+		client.Timeout = d
+	}
+
+	return client, nil
+}
+
+// post sends a JSON body, client errors are permanent. Errors name the
+// notifier instead of the url, which often contains a secret.
+func post(client *http.Client, name string, link string, headers map[string]string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, link, bytes.NewReader(body))
+	if err != nil {
+		return Permanent(fmt.Errorf("%s: %v", name, stripURL(err)))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, stripURL(err))
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	return statusError(name, resp)
+}
+
+// stripURL removes the url from request errors
+func stripURL(err error) error {
+	if uerr, ok := err.(*url.Error); ok {
+		return uerr.Err
+	}
+
+	return err
+}
+
+// statusError returns an error for unsuccessful responses, only server
+// errors and rate limits are retried
+func statusError(name string, resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+
+	err := fmt.Errorf("%s: %s", name, resp.Status)
+	if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return Permanent(err)
+	}
+
+	return err
+}
@@ -0,0 +1,91 @@
+package notifiers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookSend(t *testing.T) {
+	var body, header, contentType string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		header = r.Header.Get("X-Token")
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	n, err := New(Config{
+		Type:     "webhook",
+		URL:      server.URL,
+		Headers:  map[string]string{"X-Token": "secret"},
+		Template: `{"text": {{json .Message}}, "close": {{index .Values "close"}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = n.Send(Notification{Message: `RSI "low"`, Values: map[string]float64{"close": 1.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := `{"text": "RSI \"low\"", "close": 1.5}`; body != want {
+		t.Errorf("body is %s, want %s", body, want)
+	}
+
+	if header != "secret" {
+		t.Errorf("header is %q, want secret", header)
+	}
+
+	if contentType != "application/json" {
+		t.Errorf("content type is %q, want application/json", contentType)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	status := http.StatusInternalServerError
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := Config{Type: "webhook", URL: server.URL + "/hook/secret", Retries: 2, Backoff: "1ms"}
+
+	n, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan Result)
+	queue, err := NewQueue("webhook", n, cfg, func(r Result) { results <- r })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// server errors are retried
+	queue.Send(Notification{Message: "test"})
+
+	r := <-results
+	if r.Err == nil || IsPermanent(r.Err) || r.Attempts != 3 {
+		t.Errorf("5xx: got %v after %d attempts, want a temporary error after 3", r.Err, r.Attempts)
+	}
+
+	// client errors are not
+	status = http.StatusBadRequest
+	queue.Send(Notification{Message: "test"})
+
+	r = <-results
+	if !IsPermanent(r.Err) || r.Attempts != 1 {
+		t.Errorf("4xx: got %v after %d attempts, want a permanent error after 1", r.Err, r.Attempts)
+	}
+
+	if strings.Contains(r.Err.Error(), "secret") {
+		t.Errorf("error %v contains the url", r.Err)
+	}
+}
@@ -0,0 +1,57 @@
+package scriptstate
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+// Alert holds the result of a watcher script
+type Alert struct {
+	Fired    bool
+	Message  string
+	Severity string
+	Values   map[string]float64
+	Tags     []string
+}
+
+// SetAlert sets the Lua and Starlark function alert, which calls fn with
+// the alert described by alert(true) or by a table like
+// alert{message=..., severity=..., values={...}, tags={...}}
+func (state *State) SetAlert(fn func(Alert)) {
+	state.lua.SetGlobal("alert", state.lua.NewFunction(func(L *lua.LState) int {
+		fn(luaAlert(L.Get(1)))
+		return 0
+	}))
+
+	state.star["alert"] = starlarkAlert(fn)
+}
+
+func luaAlert(value lua.LValue) Alert {
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return Alert{Fired: lua.LVAsBool(value)}
+	}
+
+	alert := Alert{Fired: true}
+
+	if fired := table.RawGetString("fired"); fired != lua.LNil {
+		alert.Fired = lua.LVAsBool(fired)
+	}
+
+	alert.Message = lua.LVAsString(table.RawGetString("message"))
+	alert.Severity = lua.LVAsString(table.RawGetString("severity"))
+
+	if values, ok := table.RawGetString("values").(*lua.LTable); ok {
+		alert.Values = make(map[string]float64)
+		values.ForEach(func(k lua.LValue, v lua.LValue) {
+			alert.Values[lua.LVAsString(k)] = float64(lua.LVAsNumber(v))
+		})
+	}
+
+	if tags, ok := table.RawGetString("tags").(*lua.LTable); ok {
+		for i := 1; i <= tags.Len(); i++ {
+			alert.Tags = append(alert.Tags, lua.LVAsString(tags.RawGetInt(i)))
+		}
+	}
+
+	return alert
+}
@@ -0,0 +1,52 @@
+package scriptstate
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/Knetic/govaluate"
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+// Expr is a compiled expression that can be evaluated in any state
+type Expr struct {
+	mutex  sync.Mutex
+	series seriesState
+	exp    *govaluate.EvaluableExpression
+}
+
+// CompileExpr parses an expression
+func CompileExpr(expr string) (*Expr, error) {
+	e := &Expr{}
+
+	// functions read the series of the state being evaluated
+	exp, err := govaluate.NewEvaluableExpressionWithFunctions(expr, exprFunctions(&e.series))
+	if err != nil {
+		return nil, err
+	}
+
+	e.exp = exp
+
+	return e, nil
+}
+
+// Chunk is a compiled Lua chunk that can be run in any state
+type Chunk struct {
+	proto *lua.FunctionProto
+}
+
+// CompileLua parses and compiles a Lua chunk, name is used in error messages
+func CompileLua(name string, source string) (*Chunk, error) {
+	stmts, err := parse.Parse(strings.NewReader(source), name)
+	if err != nil {
+		return nil, err
+	}
+
+	proto, err := lua.Compile(stmts, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Chunk{proto: proto}, nil
+}
@@ -0,0 +1,197 @@
+package scriptstate
+
+import (
+	lua "github.com/yuin/gopher-lua"
+)
+
+// cwFuncs are the functions of the cw Lua module. Series are indexed
+// newest first, so s[1] is the current value and s[2] the previous one.
+var cwFuncs = map[string]lua.LGFunction{
+	"crossover":      cwCrossover,
+	"crossunder":     cwCrossunder,
+	"highest":        cwHighest,
+	"lowest":         cwLowest,
+	"rising":         cwRising,
+	"falling":        cwFalling,
+	"percent_change": cwPercentChange,
+	"bars_since":     cwBarsSince,
+}
+
+func cwLoader(L *lua.LState) int {
+	L.Push(L.SetFuncs(L.NewTable(), cwFuncs))
+	return 1
+}
+
+// cwAt returns the i-th value of a series, numbers are constant series
+func cwAt(L *lua.LState, series lua.LValue, i int) float64 {
+	if n, ok := series.(lua.LNumber); ok {
+		return float64(n)
+	}
+
+	return float64(lua.LVAsNumber(L.GetTable(series, lua.LNumber(i))))
+}
+
+// cwHas is true if the series has at least n values
+func cwHas(L *lua.LState, series lua.LValue, n int) bool {
+	if _, ok := series.(lua.LNumber); ok {
+		return true
+	}
+
+	return L.ObjLen(series) >= n
+}
+
+// cwLen returns the length of a series limited to the optional argument n
+func cwLen(L *lua.LState, series lua.LValue, n int) int {
+	length := L.ObjLen(series)
+
+	if n := L.OptInt(n, length); n < length {
+		return n
+	}
+
+	return length
+}
+
+// crossover(a, b) is true if a crossed above b on the current bar
+func cwCrossover(L *lua.LState) int {
+	a, b := L.CheckAny(1), L.CheckAny(2)
+	L.Push(lua.LBool(cwHas(L, a, 2) && cwHas(L, b, 2) &&
+		cwAt(L, a, 1) > cwAt(L, b, 1) && cwAt(L, a, 2) <= cwAt(L, b, 2)))
+	return 1
+}
+
+// crossunder(a, b) is true if a crossed below b on the current bar
+func cwCrossunder(L *lua.LState) int {
+	a, b := L.CheckAny(1), L.CheckAny(2)
+	L.Push(lua.LBool(cwHas(L, a, 2) && cwHas(L, b, 2) &&
+		cwAt(L, a, 1) < cwAt(L, b, 1) && cwAt(L, a, 2) >= cwAt(L, b, 2)))
+	return 1
+}
+
+// highest(series, n) returns the highest of the last n values
+func cwHighest(L *lua.LState) int {
+	series := L.CheckAny(1)
+	n := cwLen(L, series, 2)
+
+	if n < 1 {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	result := cwAt(L, series, 1)
+	for i := 2; i <= n; i++ {
+		if v := cwAt(L, series, i); v > result {
+			result = v
+		}
+	}
+
+	L.Push(lua.LNumber(result))
+	return 1
+}
+
+// lowest(series, n) returns the lowest of the last n values
+func cwLowest(L *lua.LState) int {
+	series := L.CheckAny(1)
+	n := cwLen(L, series, 2)
+
+	if n < 1 {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	result := cwAt(L, series, 1)
+	for i := 2; i <= n; i++ {
+		if v := cwAt(L, series, i); v < result {
+			result = v
+		}
+	}
+
+	L.Push(lua.LNumber(result))
+	return 1
+}
+
+// rising(series, n) is true if the series rose on each of the last n bars
+func cwRising(L *lua.LState) int {
+	series := L.CheckAny(1)
+	n := L.OptInt(2, 1)
+
+	result := cwHas(L, series, n+1)
+	for i := 1; result && i <= n; i++ {
+		result = cwAt(L, series, i) > cwAt(L, series, i+1)
+	}
+
+	L.Push(lua.LBool(result))
+	return 1
+}
+
+// falling(series, n) is true if the series fell on each of the last n bars
+func cwFalling(L *lua.LState) int {
+	series := L.CheckAny(1)
+	n := L.OptInt(2, 1)
+
+	result := cwHas(L, series, n+1)
+	for i := 1; result && i <= n; i++ {
+		result = cwAt(L, series, i) < cwAt(L, series, i+1)
+	}
+
+	L.Push(lua.LBool(result))
+	return 1
+}
+
+// percent_change(series, n) returns the change over the last n bars in percent
+func cwPercentChange(L *lua.LState) int {
+	series := L.CheckAny(1)
+	n := L.OptInt(2, 1)
+
+	if n < 1 || !cwHas(L, series, n+1) {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	base := cwAt(L, series, n+1)
+	if base == 0 {
+		L.Push(lua.LNil)
+		return 1
+	}
+
+	L.Push(lua.LNumber((cwAt(L, series, 1) - base) / base * 100))
+	return 1
+}
+
+// bars_since(condition, n) returns the number of bars since the condition
+// was last true, or nil if it was not true within the last n bars. The
+// condition is either a series of booleans or a function called with the
+// bar index.
+func cwBarsSince(L *lua.LState) int {
+	condition := L.CheckAny(1)
+
+	var n int
+	if fn, ok := condition.(*lua.LFunction); ok {
+		n = L.CheckInt(2)
+
+		for i := 1; i <= n; i++ {
+			L.Push(fn)
+			L.Push(lua.LNumber(i))
+			L.Call(1, 1)
+
+			result := L.Get(-1)
+			L.Pop(1)
+
+			if lua.LVAsBool(result) {
+				L.Push(lua.LNumber(i - 1))
+				return 1
+			}
+		}
+	} else {
+		n = cwLen(L, condition, 2)
+
+		for i := 1; i <= n; i++ {
+			if lua.LVAsBool(L.GetTable(condition, lua.LNumber(i))) {
+				L.Push(lua.LNumber(i - 1))
+				return 1
+			}
+		}
+	}
+
+	L.Push(lua.LNil)
+	return 1
+}
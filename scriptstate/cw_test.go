@@ -0,0 +1,65 @@
+package scriptstate
+
+import (
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestCw(t *testing.T) {
+	var state State
+	state.Init()
+	defer state.Close()
+
+	// newest value first
+	state.SetSeries("a", []float64{5, 3, 1, 4})
+	state.SetSeries("b", []float64{4, 4, 4, 4})
+	state.SetSeries("zero", []float64{1, 0})
+
+	tests := []struct {
+		expr string
+		want lua.LValue
+	}{
+		{"cw.crossover(a, b)", lua.LTrue},
+		{"cw.crossover(b, a)", lua.LFalse},
+		{"cw.crossover(a, 4)", lua.LTrue},
+		{"cw.crossunder(b, a)", lua.LTrue},
+		{"cw.crossunder(a, b)", lua.LFalse},
+		{"cw.crossover({5}, b)", lua.LFalse},
+		{"cw.highest(a)", lua.LNumber(5)},
+		{"cw.highest({1, 3, 7}, 2)", lua.LNumber(3)},
+		{"cw.lowest(a)", lua.LNumber(1)},
+		{"cw.lowest(a, 2)", lua.LNumber(3)},
+		{"cw.highest({})", lua.LNil},
+		{"cw.rising(a)", lua.LTrue},
+		{"cw.rising(a, 2)", lua.LTrue},
+		{"cw.rising(a, 3)", lua.LFalse},
+		{"cw.rising(a, 4)", lua.LFalse},
+		{"cw.falling(a)", lua.LFalse},
+		{"cw.falling({1, 2, 3}, 2)", lua.LTrue},
+		{"cw.percent_change(a)", lua.LNumber(200.0 / 3)},
+		{"cw.percent_change(a, 3)", lua.LNumber(25)},
+		{"cw.percent_change(zero)", lua.LNil},
+		{"cw.percent_change(a, 4)", lua.LNil},
+		{"cw.bars_since({false, false, true})", lua.LNumber(2)},
+		{"cw.bars_since({false, true, true}, 1)", lua.LNil},
+		{"cw.bars_since(function(i) return a[i] < 2 end, 4)", lua.LNumber(2)},
+		{"cw.bars_since(function(i) return false end, 4)", lua.LNil},
+	}
+
+	for _, test := range tests {
+		err := state.EvalLua(`local cw = require "cw"; result = ` + test.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+
+		got := state.lua.GetGlobal("result")
+		if n, ok := test.want.(lua.LNumber); ok {
+			if v, ok := got.(lua.LNumber); !ok || float64(v-n) > 1e-9 || float64(n-v) > 1e-9 {
+				t.Errorf("%s = %v, want %v", test.expr, got, test.want)
+			}
+		} else if got != test.want {
+			t.Errorf("%s = %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package scriptstate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/Knetic/govaluate"
+)
+
+type seriesState map[string][]float64
+
+// exprFunctions returns the expression functions backed by the series.
+// Series are passed by name as string, e.g. crossover('fast', 'slow'),
+// the second series of crossover and crossunder can be a number.
+func exprFunctions(state *seriesState) map[string]govaluate.ExpressionFunction {
+	series := func() seriesState { return *state }
+
+	return map[string]govaluate.ExpressionFunction{
+		"crossover": func(args ...interface{}) (interface{}, error) {
+			a, b, err := series().pair(args)
+			if err != nil {
+				return nil, err
+			}
+
+			return a[0] > b[0] && a[1] <= b[1], nil
+		},
+		"crossunder": func(args ...interface{}) (interface{}, error) {
+			a, b, err := series().pair(args)
+			if err != nil {
+				return nil, err
+			}
+
+			return a[0] < b[0] && a[1] >= b[1], nil
+		},
+		"abs": func(args ...interface{}) (interface{}, error) {
+			if len(args) != 1 {
+				return nil, errors.New("abs: expected one argument")
+			}
+
+			x, err := number(args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			return math.Abs(x), nil
+		},
+		"min": func(args ...interface{}) (interface{}, error) {
+			return reduce("min", args, math.Min)
+		},
+		"max": func(args ...interface{}) (interface{}, error) {
+			return reduce("max", args, math.Max)
+		},
+		"prev": func(args ...interface{}) (interface{}, error) {
+			s, n, err := series().offset("prev", args, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			return s[n], nil
+		},
+		"pct_change": func(args ...interface{}) (interface{}, error) {
+			s, n, err := series().offset("pct_change", args, 1)
+			if err != nil {
+				return nil, err
+			}
+
+			if s[n] == 0 {
+				return 0.0, nil
+			}
+
+			return (s[0] - s[n]) / s[n] * 100, nil
+		},
+	}
+}
+
+// named returns a series by name with at least n values
+func (series seriesState) named(arg interface{}, n int) ([]float64, error) {
+	name, ok := arg.(string)
+	if !ok {
+		// a series without quotes is its current value
+		return nil, fmt.Errorf("expected series name in quotes, got %v", arg)
+	}
+
+	s, ok := series[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown series %s", name)
+	}
+
+	if len(s) < n {
+		return nil, fmt.Errorf("series %s has less than %d values", name, n)
+	}
+
+	return s, nil
+}
+
+// get returns a named series or a number as constant series of length n
+func (series seriesState) get(arg interface{}, n int) ([]float64, error) {
+	v, err := number(arg)
+	if err != nil {
+		return series.named(arg, n)
+	}
+
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = v
+	}
+
+	return s, nil
+}
+
+func (series seriesState) pair(args []interface{}) ([]float64, []float64, error) {
+	if len(args) != 2 {
+		return nil, nil, errors.New("expected two series")
+	}
+
+	a, err := series.named(args[0], 2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	b, err := series.get(args[1], 2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return a, b, nil
+}
+
+// offset returns a named series and the optional bar offset argument
+func (series seriesState) offset(name string, args []interface{}, def int) ([]float64, int, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, 0, fmt.Errorf("%s: expected series name and offset", name)
+	}
+
+	n := def
+	if len(args) == 2 {
+		v, err := number(args[1])
+		if err != nil {
+			return nil, 0, err
+		}
+		n = int(v)
+	}
+
+	if n < 0 {
+		return nil, 0, fmt.Errorf("%s: negative offset %d", name, n)
+	}
+
+	s, err := series.named(args[0], n+1)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return s, n, nil
+}
+
+func number(arg interface{}) (float64, error) {
+	switch v := arg.(type) {
+	case float64:
+		return v, nil
+	case int:
+		return float64(v), nil
+	}
+
+	return 0, fmt.Errorf("expected number, got %v", arg)
+}
+
+func reduce(name string, args []interface{}, fn func(float64, float64) float64) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%s: expected at least one argument", name)
+	}
+
+	result, err := number(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, arg := range args[1:] {
+		v, err := number(arg)
+		if err != nil {
+			return nil, err
+		}
+		result = fn(result, v)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package scriptstate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExprFunctions(t *testing.T) {
+	var state State
+	state.Init()
+	defer state.Close()
+
+	// newest value first
+	state.SetSeries("fast", []float64{5, 3, 1})
+	state.SetSeries("slow", []float64{4, 4, 4})
+	state.SetSeries("zero", []float64{1, 0})
+
+	tests := []struct {
+		expr string
+		want interface{}
+	}{
+		{"crossover('fast', 'slow')", true},
+		{"crossover('slow', 'fast')", false},
+		{"crossover('fast', 4)", true},
+		{"crossunder('slow', 'fast')", true},
+		{"crossunder('fast', 4)", false},
+		{"abs(-3)", 3.0},
+		{"min(2, 1, fast)", 1.0},
+		{"max(2, 1, fast)", 5.0},
+		{"prev('fast')", 3.0},
+		{"prev('fast', 0)", 5.0},
+		{"prev('fast', 2)", 1.0},
+		{"pct_change('fast')", 200.0 / 3},
+		{"pct_change('fast', 2)", 400.0},
+		{"pct_change('zero')", 0.0},
+	}
+
+	for _, test := range tests {
+		got, err := state.EvalExpr(test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+
+		if want, ok := test.want.(float64); ok {
+			if v, ok := got.(float64); !ok || math.Abs(v-want) > 1e-9 {
+				t.Errorf("%s = %v, want %v", test.expr, got, want)
+			}
+		} else if got != test.want {
+			t.Errorf("%s = %v, want %v", test.expr, got, test.want)
+		}
+	}
+
+	invalid := []string{
+		// series without quotes are their current value
+		"prev(fast, 1)",
+		"pct_change(fast, 1)",
+		"crossover(5, 'slow')",
+		"prev('fast', 3)",
+		"prev('fast', -1)",
+		"prev('unknown')",
+		"crossover('fast')",
+	}
+
+	for _, expr := range invalid {
+		if got, err := state.EvalExpr(expr); err == nil {
+			t.Errorf("%s = %v, want an error", expr, got)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package scriptstate
+
+import "fmt"
+
+// Memory is a table of values kept across script runs
+type Memory map[string]interface{}
+
+// SetMemory sets a memory table that Lua and Starlark scripts can read
+// and modify as global name. Expressions can read its values as name_key.
+func (state *State) SetMemory(name string, memory Memory) {
+	state.memory[name] = memory
+	state.SetLua(name, memory)
+	state.syncMemory()
+}
+
+// syncMemory sets the memory tables in the Starlark and expression states.
+// Lua uses the tables directly, so they are synced before each Starlark
+// or expression run to see the changes of earlier scripts.
+func (state *State) syncMemory() {
+	// remove the values of the previous tables
+	for _, key := range state.memoryKeys {
+		delete(state.expr, key)
+	}
+	state.memoryKeys = state.memoryKeys[:0]
+
+	for name, memory := range state.memory {
+		for key, value := range memory {
+			state.SetExpr(name+"_"+key, value)
+			state.memoryKeys = append(state.memoryKeys, name+"_"+key)
+		}
+
+		if v, err := toStarlark(memory); err == nil {
+			state.star[name] = v
+		} else {
+			delete(state.star, name)
+		}
+	}
+}
+
+// Normalize converts tables stored by Lua scripts to values that can be
+// encoded as JSON
+func (memory Memory) Normalize() {
+	for key, value := range memory {
+		memory[key] = normalize(value)
+	}
+}
+
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		if array, ok := tableArray(v); ok {
+			return normalize(array)
+		}
+
+		result := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			result[fmt.Sprint(key)] = normalize(value)
+		}
+		return result
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = normalize(value)
+		}
+		return v
+	case []interface{}:
+		for i, value := range v {
+			v[i] = normalize(value)
+		}
+		return v
+	}
+
+	return value
+}
+
+// tableArray converts a Lua table with the keys 1 to n to a slice
+func tableArray(table map[interface{}]interface{}) ([]interface{}, bool) {
+	if len(table) == 0 {
+		return nil, false
+	}
+
+	array := make([]interface{}, len(table))
+	seen := make([]bool, len(table))
+
+	for key, value := range table {
+		var i int
+		switch k := key.(type) {
+		case float64:
+			if k != float64(int(k)) {
+				return nil, false
+			}
+			i = int(k)
+		case int:
+			i = k
+		default:
+			return nil, false
+		}
+
+		if i < 1 || i > len(table) || seen[i-1] {
+			return nil, false
+		}
+
+		array[i-1] = value
+		seen[i-1] = true
+	}
+
+	return array, true
+}
@@ -0,0 +1,94 @@
+package scriptstate
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+func TestMemoryArray(t *testing.T) {
+	var state State
+	state.Init()
+	defer state.Close()
+
+	memory := Memory{}
+	state.SetMemory("memory", memory)
+
+	err := state.EvalLua(`memory.hist = {10, 20, 30}; memory.table = {a = 1, [2] = 2}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memory.Normalize()
+
+	if want := []interface{}{10.0, 20.0, 30.0}; !reflect.DeepEqual(memory["hist"], want) {
+		t.Errorf("hist is %#v, want %#v", memory["hist"], want)
+	}
+
+	if want := map[string]interface{}{"a": 1.0, "2": 2.0}; !reflect.DeepEqual(memory["table"], want) {
+		t.Errorf("table is %#v, want %#v", memory["table"], want)
+	}
+
+	// memory is saved as JSON and read back in the next cycle
+	data, err := json.Marshal(memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := Memory{}
+	if err := json.Unmarshal(data, &next); err != nil {
+		t.Fatal(err)
+	}
+
+	state.SetMemory("memory", next)
+
+	err = state.EvalLua(`first, length = memory.hist[1], #memory.hist`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first := state.lua.GetGlobal("first"); first != lua.LNumber(10) {
+		t.Errorf("memory.hist[1] is %v, want 10", first)
+	}
+
+	if length := state.lua.GetGlobal("length"); length != lua.LNumber(3) {
+		t.Errorf("#memory.hist is %v, want 3", length)
+	}
+}
+
+func TestMemoryShared(t *testing.T) {
+	var state State
+	state.Init()
+	defer state.Close()
+
+	memory := Memory{}
+	state.SetMemory("pair_memory", memory)
+
+	if err := state.EvalLua(`pair_memory.lua_seen = 1`); err != nil {
+		t.Fatal(err)
+	}
+
+	prog, err := CompileStarlark("star", `pair_memory["star_seen"] = pair_memory["lua_seen"] + 1`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.RunStarlark(prog); err != nil {
+		t.Fatal(err)
+	}
+
+	if memory["lua_seen"] != 1.0 || memory["star_seen"] != 2.0 {
+		t.Errorf("memory is %v, want lua_seen 1 and star_seen 2", memory)
+	}
+
+	result, err := state.EvalExpr(`pair_memory_lua_seen + pair_memory_star_seen`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result != 3.0 {
+		t.Errorf("expression is %v, want 3", result)
+	}
+}
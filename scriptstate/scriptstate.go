@@ -1,23 +1,82 @@
 package scriptstate
 
 import (
-	"github.com/Knetic/govaluate"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
 	lua "github.com/yuin/gopher-lua"
+	"go.starlark.net/starlark"
 	luar "layeh.com/gopher-luar"
 )
 
 type exprState map[string]interface{}
 
+// LuaPath is the package.path used by require to find Lua modules
+var LuaPath = ""
+
+// Limits for the execution of Lua scripts
+var (
+	LuaTimeout         = 5 * time.Second
+	LuaCallStackSize   = 256
+	LuaRegistrySize    = 256 * 20
+	LuaRegistryMaxSize = 256 * 20 * 16
+)
+
+// luaLibs are the Lua libraries available to scripts
+var luaLibs = []struct {
+	name string
+	open lua.LGFunction
+}{
+	{lua.LoadLibName, lua.OpenPackage},
+	{lua.BaseLibName, lua.OpenBase},
+	{lua.TabLibName, lua.OpenTable},
+	{lua.StringLibName, lua.OpenString},
+	{lua.MathLibName, lua.OpenMath},
+}
+
 // State object
 type State struct {
-	expr exprState
-	lua  *lua.LState
+	expr   exprState
+	series seriesState
+	memory map[string]Memory
+	// memoryKeys are the expression variables of the memory tables
+	memoryKeys []string
+	lua        *lua.LState
+	star       starlark.StringDict
 }
 
 // Init initializes the script state
 func (state *State) Init() {
 	state.expr = make(exprState, 64)
-	state.lua = lua.NewState()
+	state.series = make(seriesState, 64)
+	state.memory = make(map[string]Memory)
+	state.star = make(starlark.StringDict, 64)
+	state.lua = lua.NewState(lua.Options{
+		SkipOpenLibs:    true,
+		CallStackSize:   LuaCallStackSize,
+		RegistrySize:    LuaRegistrySize,
+		RegistryMaxSize: LuaRegistryMaxSize,
+	})
+
+	for _, lib := range luaLibs {
+		state.lua.Push(state.lua.NewFunction(lib.open))
+		state.lua.Push(lua.LString(lib.name))
+		state.lua.Call(1, 0)
+	}
+
+	// remove access to the file system, except for modules on LuaPath
+	state.lua.SetGlobal("dofile", lua.LNil)
+	state.lua.SetGlobal("loadfile", lua.LNil)
+
+	pkg := state.lua.GetGlobal(lua.LoadLibName).(*lua.LTable)
+	pkg.RawSetString("path", lua.LString(LuaPath))
+	pkg.RawSetString("cpath", lua.LString(""))
+	pkg.RawSetString("loadlib", lua.LNil)
+
+	state.lua.PreloadModule("cw", cwLoader)
 }
 
 // Close closes the Lua state object
@@ -35,32 +94,161 @@ func (state *State) SetExpr(name string, value interface{}) {
 	state.expr[name] = value
 }
 
-// SetAll sets a global variable in both states
+// SetAll sets a global variable in all states
 func (state *State) SetAll(name string, value interface{}) {
 	state.SetExpr(name, value)
 	state.SetLua(name, value)
+	state.SetStarlark(name, value)
 }
 
-// SetBoth sets a global variable in all states to separate values
+// SetBoth sets a global variable in all states to separate values, the
+// Lua value is also used for Starlark
 func (state *State) SetBoth(name string, exprValue interface{}, luaValue interface{}) {
 	state.SetExpr(name, exprValue)
 	state.SetLua(name, luaValue)
+	state.SetStarlark(name, luaValue)
+}
+
+// SetSeries sets a series, newest value first, in all states. The
+// expression state holds the newest value, the full series is available
+// to expression functions.
+func (state *State) SetSeries(name string, series []float64) {
+	if len(series) > 0 {
+		state.SetExpr(name, series[0])
+	}
+
+	state.SetLua(name, series)
+	state.SetStarlark(name, series)
+	state.series[name] = series
 }
 
 // EvalExpr evaluates the expression with the current state
 func (state *State) EvalExpr(expr string) (interface{}, error) {
-	exp, err := govaluate.NewEvaluableExpression(expr)
+	exp, err := CompileExpr(expr)
 
 	if err != nil {
 		return nil, err
-	} else {
-		res, err := exp.Evaluate(state.expr)
-
-		return res, err
 	}
+
+	return state.RunExpr(exp)
+}
+
+// RunExpr evaluates a compiled expression with the current state
+func (state *State) RunExpr(exp *Expr) (interface{}, error) {
+	exp.mutex.Lock()
+	defer exp.mutex.Unlock()
+
+	exp.series = state.series
+	defer func() { exp.series = nil }()
+
+	state.syncMemory()
+
+	return exp.exp.Evaluate(state.expr)
 }
 
 // EvalLua evaluates the expression with the current state
 func (state *State) EvalLua(lua string) error {
-	return state.lua.DoString(lua)
+	chunk, err := CompileLua("<string>", lua)
+
+	if err != nil {
+		return err
+	}
+
+	return state.RunLua(chunk)
+}
+
+// RunLua runs a compiled Lua chunk with the current state
+func (state *State) RunLua(chunk *Chunk) error {
+	top := state.lua.GetTop()
+	defer state.lua.SetTop(top)
+
+	return state.call(chunk)
+}
+
+// RunLuaSeries runs a compiled Lua chunk that returns one or more series.
+// The chunk may return a single array, which is stored under the empty
+// name, or a table mapping names to arrays.
+func (state *State) RunLuaSeries(chunk *Chunk) (map[string][]float64, error) {
+	top := state.lua.GetTop()
+	defer state.lua.SetTop(top)
+
+	if err := state.call(chunk); err != nil {
+		return nil, err
+	}
+
+	if state.lua.GetTop() == top {
+		return nil, errors.New("lua: no series returned")
+	}
+
+	return luaSeries(state.lua.Get(top + 1))
+}
+
+// call runs a chunk, cancelling it after LuaTimeout
+func (state *State) call(chunk *Chunk) error {
+	ctx, cancel := context.WithTimeout(context.Background(), LuaTimeout)
+	defer cancel()
+
+	state.lua.SetContext(ctx)
+	defer state.lua.RemoveContext()
+
+	state.lua.Push(state.lua.NewFunctionFromProto(chunk.proto))
+	return state.lua.PCall(0, lua.MultRet, nil)
+}
+
+func luaSeries(value lua.LValue) (map[string][]float64, error) {
+	if series, ok := luaArray(value); ok {
+		return map[string][]float64{"": series}, nil
+	}
+
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("lua: expected series, got %s", value.Type())
+	}
+
+	var names []string
+	table.ForEach(func(k lua.LValue, _ lua.LValue) {
+		if name, ok := k.(lua.LString); ok {
+			names = append(names, string(name))
+		}
+	})
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil, errors.New("lua: empty series returned")
+	}
+
+	result := make(map[string][]float64, len(names))
+	for _, name := range names {
+		series, ok := luaArray(table.RawGetString(name))
+		if !ok {
+			return nil, fmt.Errorf("lua: %s is not a series", name)
+		}
+		result[name] = series
+	}
+
+	return result, nil
+}
+
+func luaArray(value lua.LValue) ([]float64, bool) {
+	switch v := value.(type) {
+	case *lua.LTable:
+		n := v.Len()
+		if n == 0 {
+			return nil, false
+		}
+
+		series := make([]float64, n)
+		for i := range series {
+			series[i] = float64(lua.LVAsNumber(v.RawGetInt(i + 1)))
+		}
+		return series, true
+	case *lua.LUserData:
+		if s, ok := v.Value.([]float64); ok {
+			series := make([]float64, len(s))
+			copy(series, s)
+			return series, true
+		}
+	}
+
+	return nil, false
 }
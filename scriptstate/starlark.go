@@ -0,0 +1,252 @@
+package scriptstate
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Limits for the execution of Starlark scripts
+var (
+	StarlarkTimeout  = 5 * time.Second
+	StarlarkMaxSteps = uint64(10000000)
+)
+
+// scripts are written as top-level code
+var starlarkOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// StarlarkProgram is a compiled Starlark script that can be run in any state
+type StarlarkProgram struct {
+	program *starlark.Program
+}
+
+// CompileStarlark parses and compiles a Starlark script, name is used in
+// error messages. known reports the variables that will be set when the
+// script runs, other names are undefined. If known is nil every name is
+// accepted.
+func CompileStarlark(name string, source string, known func(string) bool) (*StarlarkProgram, error) {
+	isPredeclared := func(name string) bool {
+		return !starlark.Universe.Has(name) && (known == nil || known(name))
+	}
+
+	_, program, err := starlark.SourceProgramOptions(starlarkOptions, name, source, isPredeclared)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StarlarkProgram{program: program}, nil
+}
+
+// SetStarlark sets a global variable in the Starlark state
+func (state *State) SetStarlark(name string, value interface{}) {
+	if v, err := toStarlark(value); err == nil {
+		state.star[name] = v
+	}
+}
+
+// RunStarlark runs a compiled Starlark script with the current state
+func (state *State) RunStarlark(prog *StarlarkProgram) error {
+	thread := &starlark.Thread{Name: "scriptstate"}
+	thread.SetMaxExecutionSteps(StarlarkMaxSteps)
+
+	timer := time.AfterFunc(StarlarkTimeout, func() { thread.Cancel("timeout") })
+	defer timer.Stop()
+
+	state.syncMemory()
+
+	_, err := prog.program.Init(thread, state.star)
+	if err != nil {
+		err = undefinedName(err)
+	}
+
+	// copy changes of memory tables back
+	for name, memory := range state.memory {
+		dict, ok := state.star[name].(*starlark.Dict)
+		if !ok {
+			continue
+		}
+
+		for key := range memory {
+			delete(memory, key)
+		}
+
+		for _, item := range dict.Items() {
+			memory[starlarkKey(item[0])] = fromStarlark(item[1])
+		}
+	}
+
+	return err
+}
+
+var uninitialized = regexp.MustCompile(`predeclared variable (\w+) is uninitialized`)
+
+// undefinedName replaces the error for variables that were not set, which
+// Starlark reports as internal error
+func undefinedName(err error) error {
+	if m := uninitialized.FindStringSubmatch(err.Error()); m != nil {
+		return fmt.Errorf("undefined name %s", m[1])
+	}
+
+	return err
+}
+
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case starlark.Value:
+		return v, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case string:
+		return starlark.String(v), nil
+	case []float64:
+		tuple := make(starlark.Tuple, len(v))
+		for i, x := range v {
+			tuple[i] = starlark.Float(x)
+		}
+		return tuple, nil
+	case []string:
+		tuple := make(starlark.Tuple, len(v))
+		for i, x := range v {
+			tuple[i] = starlark.String(x)
+		}
+		return tuple, nil
+	case []interface{}:
+		list := make([]starlark.Value, len(v))
+		for i, x := range v {
+			item, err := toStarlark(x)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = item
+		}
+		return starlark.NewList(list), nil
+	case Memory:
+		return toStarlark(map[string]interface{}(v))
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			item, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			dict.SetKey(starlark.String(key), item)
+		}
+		return dict, nil
+	case map[interface{}]interface{}:
+		return toStarlark(normalize(v))
+	}
+
+	return nil, fmt.Errorf("starlark: unsupported value %T", value)
+}
+
+func fromStarlark(value starlark.Value) interface{} {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return float64(i)
+		}
+		f, _ := starlark.AsFloat(v)
+		return f
+	case starlark.Float:
+		return float64(v)
+	case starlark.String:
+		return string(v)
+	case *starlark.Dict:
+		result := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			result[starlarkKey(item[0])] = fromStarlark(item[1])
+		}
+		return result
+	case starlark.Indexable:
+		result := make([]interface{}, v.Len())
+		for i := range result {
+			result[i] = fromStarlark(v.Index(i))
+		}
+		return result
+	}
+
+	return value.String()
+}
+
+func starlarkKey(key starlark.Value) string {
+	if s, ok := key.(starlark.String); ok {
+		return string(s)
+	}
+
+	return key.String()
+}
+
+// starlarkAlert is the Starlark alert builtin, called as alert(True) or
+// alert(message=..., severity=..., values={...}, tags=[...])
+func starlarkAlert(fn func(Alert)) *starlark.Builtin {
+	return starlark.NewBuiltin("alert", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		fired := true
+		var message, severity string
+		var values *starlark.Dict
+		var tags starlark.Iterable
+
+		err := starlark.UnpackArgs(b.Name(), args, kwargs,
+			"fired?", &fired,
+			"message?", &message,
+			"severity?", &severity,
+			"values?", &values,
+			"tags?", &tags)
+		if err != nil {
+			return nil, err
+		}
+
+		alert := Alert{Fired: fired, Message: message, Severity: severity}
+
+		if values != nil {
+			alert.Values = make(map[string]float64)
+			for _, item := range values.Items() {
+				v, ok := starlark.AsFloat(item[1])
+				if !ok {
+					return nil, fmt.Errorf("%s: value %s is not a number", b.Name(), item[0])
+				}
+				alert.Values[starlarkKey(item[0])] = v
+			}
+		}
+
+		if tags != nil {
+			iter := tags.Iterate()
+			defer iter.Done()
+
+			var tag starlark.Value
+			for iter.Next(&tag) {
+				alert.Tags = append(alert.Tags, starlarkKey(tag))
+			}
+		}
+
+		fn(alert)
+
+		return starlark.None, nil
+	})
+}
@@ -0,0 +1,34 @@
+package scriptstate
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStarlarkUndefinedName(t *testing.T) {
+	known := func(name string) bool { return name == "close" }
+
+	if _, err := CompileStarlark("known", `x = close[0] + len([])`, known); err != nil {
+		t.Errorf("known names: %v", err)
+	}
+
+	_, err := CompileStarlark("typo", `x = clsoe[0]`, known)
+	if err == nil || !strings.Contains(err.Error(), "undefined: clsoe") {
+		t.Errorf("misspelled name: got error %v", err)
+	}
+
+	// without known names, unset variables fail when the script runs
+	var state State
+	state.Init()
+	defer state.Close()
+
+	prog, err := CompileStarlark("unset", `x = clsoe[0]`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = state.RunStarlark(prog)
+	if err == nil || err.Error() != "undefined name clsoe" {
+		t.Errorf("unset name: got error %v", err)
+	}
+}
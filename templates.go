@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type selector struct {
+	Coin     string   `json:"coin" yaml:"coin"`
+	Currency string   `json:"currency" yaml:"currency"`
+	Exchange string   `json:"exchange" yaml:"exchange"`
+	Tags     []string `json:"tags" yaml:"tags"`
+}
+
+type watcherTemplate struct {
+	Name    string                 `json:"name" yaml:"name"`
+	Match   *selector              `json:"match" yaml:"match"`
+	Params  map[string]interface{} `json:"params" yaml:"params"`
+	Watcher watcher                `json:"watcher" yaml:"watcher"`
+}
+
+// indicatorTemplate creates an indicator, its periods can be overridden
+// with IndicatorParams, e.g. ["${period}", "3"], which replace the params
+// of the indicator
+type indicatorTemplate struct {
+	Name            string                 `json:"name" yaml:"name"`
+	Match           *selector              `json:"match" yaml:"match"`
+	Params          map[string]interface{} `json:"params" yaml:"params"`
+	IndicatorParams []string               `json:"indicator_params" yaml:"indicator_params"`
+	Indicator       indicator              `json:"indicator" yaml:"indicator"`
+}
+
+var placeholder = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// matches checks if a tradingpair is selected, empty fields match all
+// pairs and tags match if the pair has any of them
+func (s *selector) matches(t tradingpair) bool {
+	if s == nil {
+		return false
+	}
+
+	if s.Coin != "" && !strings.EqualFold(s.Coin, t.Coin) {
+		return false
+	}
+
+	if s.Currency != "" && !strings.EqualFold(s.Currency, t.Currency) {
+		return false
+	}
+
+	if s.Exchange != "" && !strings.EqualFold(s.Exchange, t.Exchange) {
+		return false
+	}
+
+	if len(s.Tags) == 0 {
+		return true
+	}
+
+	for _, tag := range s.Tags {
+		for _, pairTag := range t.Tags {
+			if tag == pairTag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// applies checks if a template is referenced by or matches a tradingpair
+func applies(name string, match *selector, t tradingpair) bool {
+	for _, template := range t.Templates {
+		if template == name {
+			return true
+		}
+	}
+
+	return match.matches(t)
+}
+
+// expand replaces ${name} placeholders, the tradingpair parameters of the
+// template override its defaults
+func expand(s string, template string, defaults map[string]interface{}, t tradingpair) (string, error) {
+	var err error
+
+	result := placeholder.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholder.FindStringSubmatch(match)[1]
+
+		if v, ok := t.Params[template][name]; ok {
+			return fmt.Sprint(v)
+		}
+
+		if v, ok := defaults[name]; ok {
+			return fmt.Sprint(v)
+		}
+
+		err = fmt.Errorf("missing parameter %s", name)
+		return match
+	})
+
+	return result, err
+}
+
+func (wt watcherTemplate) instantiate(t tradingpair) (watcher, error) {
+	w := wt.Watcher
+
+	fields := []*string{&w.Name, &w.Lua, &w.Starlark, &w.Expr}
+	for _, field := range fields {
+		value, err := expand(*field, wt.Name, wt.Params, t)
+		if err != nil {
+			return w, err
+		}
+		*field = value
+	}
+
+	w.Values = append([]string(nil), w.Values...)
+	w.Tags = append([]string(nil), w.Tags...)
+
+	return w, nil
+}
+
+func (it indicatorTemplate) instantiate(t tradingpair) (indicator, error) {
+	idc := it.Indicator
+
+	fields := []*string{&idc.Name, &idc.Lua}
+	for _, field := range fields {
+		value, err := expand(*field, it.Name, it.Params, t)
+		if err != nil {
+			return idc, err
+		}
+		*field = value
+	}
+
+	idc.Params = append([]int(nil), idc.Params...)
+
+	if it.IndicatorParams != nil {
+		idc.Params = nil
+
+		for _, param := range it.IndicatorParams {
+			value, err := expand(param, it.Name, it.Params, t)
+			if err != nil {
+				return idc, err
+			}
+
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return idc, fmt.Errorf("param %s is not an integer", value)
+			}
+
+			idc.Params = append(idc.Params, n)
+		}
+	}
+
+	return idc, nil
+}
+
+// applyTemplates adds the indicators and watchers of all templates that
+// apply to a tradingpair, unless it defines one with the same name
+func applyTemplates() error {
+	templates := make(map[string]bool)
+	for _, it := range config.IndicatorTemplates {
+		templates[it.Name] = true
+	}
+	for _, wt := range config.WatcherTemplates {
+		templates[wt.Name] = true
+	}
+
+	for i := range config.Tradingpairs {
+		t := &config.Tradingpairs[i]
+
+		for _, name := range t.Templates {
+			if !templates[name] {
+				return fmt.Errorf("%s: unknown template %s", t.Name, name)
+			}
+		}
+
+		for name := range t.Params {
+			if !templates[name] {
+				return fmt.Errorf("%s: params for unknown template %s", t.Name, name)
+			}
+		}
+
+		indicators := make(map[string]bool)
+		for _, idc := range t.Indicators {
+			indicators[idc.Name] = true
+		}
+
+		for _, it := range config.IndicatorTemplates {
+			if !applies(it.Name, it.Match, *t) {
+				continue
+			}
+
+			idc, err := it.instantiate(*t)
+			if err != nil {
+				return fmt.Errorf("%s indicator template %s: %v", t.Name, it.Name, err)
+			}
+
+			if !indicators[idc.Name] {
+				indicators[idc.Name] = true
+				t.Indicators = append(t.Indicators, idc)
+			}
+		}
+
+		watchers := make(map[string]bool)
+		for _, w := range t.Watchers {
+			watchers[w.Name] = true
+		}
+
+		for _, wt := range config.WatcherTemplates {
+			if !applies(wt.Name, wt.Match, *t) {
+				continue
+			}
+
+			w, err := wt.instantiate(*t)
+			if err != nil {
+				return fmt.Errorf("%s watcher template %s: %v", t.Name, wt.Name, err)
+			}
+
+			if !watchers[w.Name] {
+				watchers[w.Name] = true
+				t.Watchers = append(t.Watchers, w)
+			}
+		}
+	}
+
+	return nil
+}